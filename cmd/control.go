@@ -0,0 +1,103 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"sync"
+	"time"
+
+	"github.com/shldhll/hourglass/control"
+	"github.com/shldhll/hourglass/daemon"
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/system"
+	"github.com/shldhll/hourglass/tracker"
+	"github.com/spf13/viper"
+)
+
+// trackerHandler answers control socket requests for the running tracker
+type trackerHandler struct {
+	status    *tracker.Status
+	db        data.DB
+	cfg       *system.SharedConfig
+	pausePath string
+}
+
+// Pause pauses tracking, for the given duration unless it is zero, and
+// remembers the pause across restarts of the tracker
+func (h trackerHandler) Pause(duration time.Duration) error {
+	var until time.Time
+	if duration > 0 {
+		until = time.Now().Add(duration)
+	}
+
+	h.status.Pause(until)
+	return daemon.SavePause(h.pausePath, daemon.PauseState{Paused: true, Until: until})
+}
+
+// Resume resumes tracking
+func (h trackerHandler) Resume() error {
+	h.status.Resume()
+	return daemon.SavePause(h.pausePath, daemon.PauseState{})
+}
+
+// Current returns the application currently tracked
+func (h trackerHandler) Current() (control.Current, error) {
+	appName, since := h.status.Current()
+	paused, until := h.status.Paused()
+	return control.Current{
+		AppName:     appName,
+		Since:       since,
+		Paused:      paused,
+		PausedUntil: until,
+	}, nil
+}
+
+// Today returns the entries of the current day
+func (h trackerHandler) Today() ([]data.Entry, error) {
+	day := data.Day(time.Now(), h.cfg.GetDayStart())
+	return h.db.ReadList(day.Format(data.EntryIDDateFormat))
+}
+
+// reloadMu serializes config reloads requested over the control socket
+var reloadMu sync.Mutex
+
+// ReloadConfig reads the config file again and applies it to the tracker.
+// The file is read into a viper instance of its own, as viper is not safe
+// for concurrent use and requests are handled concurrently.
+func (h trackerHandler) ReloadConfig() error {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	v, err := readConfig()
+	if err != nil {
+		return err
+	}
+	cfg, err := loadConfig(v)
+	if err != nil {
+		return err
+	}
+	h.cfg.Set(cfg)
+	return nil
+}
+
+// loadConfig returns the tracker configuration read from v
+func loadConfig(v *viper.Viper) (system.Config, error) {
+	start, err := dayStart(v)
+	if err != nil {
+		return nil, err
+	}
+	return system.GetConfig(v.GetDuration("cooldown"), v.GetDuration("min_usage"), v.GetDuration("idle_threshold"), start), nil
+}
@@ -0,0 +1,125 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/report"
+	"github.com/shldhll/hourglass/tracker"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	fromFlagUsage = "first day of the report: ISO date (2006-01-02) or expression such as yesterday, \"last monday\", -7d, this-month"
+	toFlagUsage   = "last day of the report, accepts the same expressions as --from"
+
+	groupByFlagUsage = "group the time spent by app, process or title"
+	tzFlagUsage      = "time zone of the report, such as UTC or Europe/Berlin, with days re-bucketed from the recorded sessions (default is the current zone)"
+)
+
+// addRangeFlags registers the --from, --to and --tz flags on the given command
+func addRangeFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from", "today", fromFlagUsage)
+	cmd.Flags().String("to", "today", toFlagUsage)
+	cmd.Flags().String("tz", "", tzFlagUsage)
+}
+
+// addGroupByFlag registers the --group-by flag on the given command
+func addGroupByFlag(cmd *cobra.Command) {
+	cmd.Flags().String("group-by", report.GroupByApp, groupByFlagUsage)
+}
+
+// groupByFromFlags returns the --group-by flag of the given command after
+// checking that sessions can be grouped by it
+func groupByFromFlags(cmd *cobra.Command) (string, error) {
+	groupBy, err := cmd.Flags().GetString("group-by")
+	if err != nil {
+		return "", err
+	}
+	_, err = report.GroupKey(groupBy)
+	return strings.ToLower(groupBy), err
+}
+
+// rangeFromFlags parses the --from and --to flags of the given command
+func rangeFromFlags(cmd *cobra.Command) (report.Range, error) {
+	from, err := cmd.Flags().GetString("from")
+	if err != nil {
+		return report.Range{}, err
+	}
+	to, err := cmd.Flags().GetString("to")
+	if err != nil {
+		return report.Range{}, err
+	}
+	loc, err := locationFromFlags(cmd)
+	if err != nil {
+		return report.Range{}, err
+	}
+	return parseRange(from, to, loc)
+}
+
+// locationFromFlags returns the zone selected with the --tz flag of the
+// given command, or the current zone
+func locationFromFlags(cmd *cobra.Command) (*time.Location, error) {
+	tz, err := cmd.Flags().GetString("tz")
+	if err != nil || tz == "" {
+		return time.Local, err
+	}
+	return time.LoadLocation(tz)
+}
+
+// parseRange parses the given start and end expressions relative to the
+// current day in loc, which starts at the configured day start
+func parseRange(from, to string, loc *time.Location) (report.Range, error) {
+	start, err := dayStart(viper.GetViper())
+	if err != nil {
+		return report.Range{}, err
+	}
+	r, err := report.ParseRange(from, to, data.Day(time.Now().In(loc), start))
+	r.DayStart = start
+	return r, err
+}
+
+// dayStart returns the time after midnight at which a day starts, as
+// configured in v
+func dayStart(v *viper.Viper) (time.Duration, error) {
+	start := v.GetDuration("day_start")
+	if start < 0 || start >= 24*time.Hour {
+		return 0, fmt.Errorf("%s: %v", tracker.ErrDayStartText, start)
+	}
+	return start, nil
+}
+
+// sessionLookback is how long before the start of a range sessions are read
+// from. The tracker splits sessions at the start of each day in the zone they
+// are recorded in, so none is longer than a day, which is 25 hours at most.
+const sessionLookback = 25 * time.Hour
+
+// readSessions returns the sessions overlapping the given range, in the zone
+// of the range and clipped to it
+func readSessions(db data.DB, r report.Range) ([]data.Session, error) {
+	sessions, err := db.ReadSessions(r.Start().Add(-sessionLookback), r.End())
+	for i := range sessions {
+		sessions[i] = sessions[i].In(r.From.Location())
+	}
+	return r.Clip(sessions), err
+}
+
+// recordedInZone reports whether every session was recorded with the offset
+// its start has in loc, so the entries of their days were summed up in the
+// same days as sessions bucketed in loc
+func recordedInZone(sessions []data.Session, loc *time.Location) bool {
+	for _, session := range sessions {
+		if _, offset := session.Start.In(loc).Zone(); offset != session.Offset {
+			return false
+		}
+	}
+	return true
+}
+
+// readEntries returns the entries of every day in the given range
+func readEntries(db data.DB, r report.Range) ([]data.Entry, error) {
+	return db.ReadRange(r.From.Format(data.EntryIDDateFormat), r.To.Format(data.EntryIDDateFormat))
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/shldhll/hourglass/data"
+	"github.com/spf13/viper"
+)
+
+// errNoData is returned when the tracker has not created a database yet
+var errNoData = errors.New("no tracking data found, start tracking with: hourglass start")
+
+// storage returns the configured storage driver and the location of its
+// database. Unless storage.path is set, the database is kept in the
+// hourglass directory.
+func storage() (string, string) {
+	driver := strings.ToLower(viper.GetString("storage.driver"))
+	location := viper.GetString("storage.path")
+	if location == "" {
+		location = data.Location(driver, os.Getenv("HOME")+"/.hourglass")
+	}
+	return driver, location
+}
+
+// openReadOnlyDB opens the tracking database for reading. It works while a
+// tracker is writing to the database.
+func openReadOnlyDB() (data.Store, error) {
+	driver, location := storage()
+	db, err := data.OpenReadOnly(driver, location)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errNoData
+	}
+	return db, err
+}
+
+func init() {
+	setDefault("storage.driver", data.DriverBadger)
+}
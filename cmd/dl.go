@@ -16,161 +16,155 @@ limitations under the License.
 package cmd
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"os/exec"
-	"sort"
+	"path/filepath"
 	"strings"
-	"text/template"
 	"time"
 
-	"github.com/dgraph-io/badger"
-	"github.com/shldhll/hourglass/data"
-	"github.com/shldhll/hourglass/tracker"
+	"github.com/shldhll/hourglass/report"
 	"github.com/spf13/cobra"
 )
 
-const idStringSeparator = "_"
-const htmlCode = `<!DOCTYPE html>
-<html>
-    <head>
-        <title>Tracking Data</title>
-        <link rel="stylesheet" href="https://stackpath.bootstrapcdn.com/bootstrap/4.1.3/css/bootstrap.min.css" integrity="sha384-MCw98/SFnGE8fJT3GXwEOngsV7Zt27NXFoaoApmYm81iuXoPkFOJwJ8ERdknLPMO" crossorigin="anonymous">
-    </head>
-    <body>
-		<center><h1 class="display-3">hourglass</h1></center>
-        {{with .Entries}}
-        <table class="table">
-            <thead>
-              <tr>
-                <th scope="col">Date</th>
-                <th scope="col">Application</th>
-                <th scope="col">Duration</th>
-              </tr>
-            </thead>
-            <tbody>
-              {{range .}}
-              <tr>
-                <th scope="row">{{.Date}}</th>
-                <td>{{.AppName}}</td>
-                <td>{{.Duration}}</td>
-              </tr>
-              {{end}}
-            </tbody>
-          </table>
-          {{end}}
-    </body>
-</html>`
-
-type task struct {
-	Date     string
-	AppName  string
-	Duration string
+const dlUsage = "usage: hourglass dl [--from <date>] [--to <date>] [--format csv|json|ndjson|html] [--group-by app|process|title] [today|week|month] <filename|->"
+
+// errPeriodWithRange is returned when a period is given together with --from
+// or --to, which it would override
+var errPeriodWithRange = errors.New("a period cannot be combined with --from or --to")
+
+// legacyPeriods maps the periods accepted as first argument to date ranges
+var legacyPeriods = map[string][2]string{
+	"today": {"today", "today"},
+	"week":  {"-6d", "today"},
+	"month": {"-29d", "today"},
 }
 
 // dlCmd represents the dl command
 var dlCmd = &cobra.Command{
-	Use:   "dl",
+	Use:   "dl [today|week|month] <filename|->",
 	Short: "Download the tracking data",
+	Long:  "Download the tracking data as CSV, JSON, NDJSON or HTML. Use - as filename to write to stdout.",
 	Run: func(cmd *cobra.Command, args []string) {
-		var startTime time.Time
-		if len(args) < 2 {
-			println("usage: hourglass dl [today|week|month] <filename.html>")
+		if len(args) < 1 || len(args) > 2 {
+			println(dlUsage)
 			return
 		}
 
-		if args[0] == "today" {
-			startTime = time.Now()
-		} else if args[0] == "week" {
-			startTime = time.Now().AddDate(0, -6, 0)
-		} else if args[0] == "month" {
-			startTime = time.Now().AddDate(0, -30, 0)
-		} else {
-			println("usage: hourglass dl [today|week|month] <filename.html>")
-			return
+		r, err := rangeFromFlags(cmd)
+		if len(args) == 2 {
+			period, ok := legacyPeriods[args[0]]
+			if !ok {
+				println(dlUsage)
+				return
+			}
+			if cmd.Flags().Changed("from") || cmd.Flags().Changed("to") {
+				println("error occured:", errPeriodWithRange.Error())
+				return
+			}
+			var loc *time.Location
+			if loc, err = locationFromFlags(cmd); err == nil {
+				r, err = parseRange(period[0], period[1], loc)
+			}
 		}
-
-		entries := dl(startTime)
-		sort.Slice(entries[:], func(i, j int) bool {
-			return entries[i].Duration > entries[j].Duration
-		})
-
-		ok := make([]task, 0)
-		for _, e := range entries {
-			var t task
-			t.Date = strings.Split(e.ID, "_")[0]
-			t.AppName = e.AppName
-			duration := e.Duration.Round(time.Second)
-			h := duration / time.Hour
-			duration -= h * time.Hour
-			m := duration / time.Minute
-			duration -= m * time.Minute
-			s := duration / time.Second
-			t.Duration = fmt.Sprintf("%02d:%02d:%02d", h, m, s)
-			ok = append(ok, t)
+		if err != nil {
+			println("error occured:", err.Error())
+			return
 		}
 
-		t, err := template.New("data").Parse(htmlCode)
+		groupBy, err := groupByFromFlags(cmd)
 		if err != nil {
-			println("error occured:", err)
+			println("error occured:", err.Error())
 			return
 		}
 
-		var result bytes.Buffer
+		fileName := args[len(args)-1]
+		format, _ := cmd.Flags().GetString("format")
+		if !cmd.Flags().Changed("format") && fileName != "-" {
+			if ext := strings.TrimPrefix(filepath.Ext(fileName), "."); ext != "" {
+				if _, err := report.GetExporter(ext); err == nil {
+					format = ext
+				}
+			}
+		}
 
-		err = t.Execute(&result, struct{ Entries []task }{Entries: ok})
+		exporter, err := report.GetExporter(format)
 		if err != nil {
+			println("error occured:", err.Error())
 			return
 		}
 
-		fileName := args[1]
-		if !strings.Contains(fileName, ".html") {
-			fileName += ".html"
-		}
-
-		f, err := os.Create(fileName)
+		rep, err := dl(r, groupBy)
 		if err != nil {
-			println("error occured:", err)
+			log.Fatal(err)
+			return
 		}
 
-		defer f.Close()
+		var w io.Writer = os.Stdout
+		if fileName != "-" {
+			if !strings.HasSuffix(fileName, exporter.Extension()) {
+				fileName += exporter.Extension()
+			}
+
+			f, err := os.Create(fileName)
+			if err != nil {
+				println("error occured:", err.Error())
+				return
+			}
+			defer f.Close()
+			w = f
+		}
 
-		_, err = f.WriteString(result.String())
+		err = exporter.Export(w, rep)
 		if err != nil {
-			println("error occured:", err)
+			println("error occured:", err.Error())
+			return
 		}
 
-		fmt.Println("Data saved to", fileName)
+		if fileName != "-" {
+			fmt.Println("Data saved to", fileName)
+		}
 	},
 }
 
-func dl(start time.Time) []data.Entry {
-	entries := make([]data.Entry, 0)
-	_, err := exec.Command("mkdir", "-p", os.Getenv("HOME")+"/.hourglass").Output()
+// dl reads the report of the given range, with rows grouped by groupBy. Rows
+// are summed up from the sessions in the zone of the range, unless they are
+// grouped by report.GroupByApp and every session was recorded in that zone,
+// in which case the stored entries are used.
+func dl(r report.Range, groupBy string) (report.Report, error) {
+	rep := report.Report{Range: r}
+	db, err := openReadOnlyDB()
 	if err != nil {
-		log.Fatal(err)
-		return entries
+		return rep, err
 	}
-	db, err := data.GetBadgerDB(os.Getenv("HOME")+"/.hourglass/data", data.BadgerDBUtilsDefault{})
+	defer db.Close()
+
+	rep.Sessions, err = readSessions(db, r)
 	if err != nil {
-		log.Print("db error:", err)
-		return entries
+		return rep, err
 	}
-	for start.Format(tracker.EntryIDDateFormat) != time.Now().AddDate(0, 1, 0).Format(tracker.EntryIDDateFormat) {
-		ok, err := db.ReadList(start.Format(tracker.EntryIDDateFormat))
-		start = start.AddDate(0, 1, 0)
-		if err != nil && err != badger.ErrKeyNotFound {
-			println("error while downloading:", err.Error())
-			continue
-		}
-		entries = append(entries, ok...)
+
+	if groupBy != report.GroupByApp || !recordedInZone(rep.Sessions, r.From.Location()) {
+		rep.Rows, err = report.RowsFromSessions(rep.Sessions, groupBy, r.DayStart)
+		return rep, err
 	}
 
-	return entries
+	entries, err := readEntries(db, r)
+	if err != nil {
+		return rep, err
+	}
+	rep.Rows = report.RowsFromEntries(entries)
+
+	return rep, nil
 }
 
 func init() {
 	rootCmd.AddCommand(dlCmd)
+	addRangeFlags(dlCmd)
+
+	addGroupByFlag(dlCmd)
+
+	dlCmd.Flags().String("format", report.FormatHTML, "output format: csv, json, ndjson or html")
 }
@@ -0,0 +1,142 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/shldhll/hourglass/control"
+	"github.com/shldhll/hourglass/daemon"
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/report"
+	"github.com/shldhll/hourglass/system"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// doctorCmd represents the doctor command
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Diagnose the tracking setup",
+	Long:  "Show which backend reads the focused window, a live sample of it, where the configuration is read from and whether the database can be opened",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := os.Getenv("HOME") + "/.hourglass"
+
+		fmt.Println("Backends:")
+		detection := system.Detect()
+		for _, probe := range detection.Probes {
+			if probe.Available() {
+				fmt.Printf("  %-10s ok\n", probe.Backend)
+			} else {
+				fmt.Printf("  %-10s %s\n", probe.Backend, probe.Err)
+			}
+		}
+
+		backend := strings.ToLower(viper.GetString("backend"))
+		if backend == "" || backend == system.BackendAuto {
+			backend = detection.Selected
+			fmt.Println("Backend:\t", backend, "(detected)")
+		} else {
+			fmt.Println("Backend:\t", backend, "(configured)")
+		}
+		for _, probe := range detection.Probes {
+			if probe.Backend == backend && !probe.Available() {
+				fmt.Println("Warning:\t", "the backend cannot be used, the focused window will not be recorded")
+			}
+		}
+		doctorSample(backend)
+
+		if file := viper.ConfigFileUsed(); file != "" {
+			fmt.Println("Config file:\t", file)
+		} else {
+			fmt.Println("Config file:\t", "none, using defaults")
+		}
+
+		driver, location := storage()
+		fmt.Println("Storage:\t", driver)
+		fmt.Println("Data:\t\t", location)
+		if state, err := daemon.Status(dir + "/" + daemon.PIDFileName); err == nil {
+			fmt.Println("Tracker:\t", fmt.Sprintf("running (pid %d), database locked", state.PID))
+			if _, err := control.Send(dir+"/"+control.SocketFileName, control.CommandCurrent); err != nil {
+				fmt.Println("Control socket:\t", err)
+			} else {
+				fmt.Println("Control socket:\t", "ok")
+			}
+		} else {
+			fmt.Println("Tracker:\t", err)
+		}
+
+		db, err := openReadOnlyDB()
+		if err != nil {
+			fmt.Println("Database:\t", err)
+			return
+		}
+		defer db.Close()
+
+		r, err := parseRange("today", "today", time.Local)
+		if err == nil {
+			var entries []data.Entry
+			entries, err = readEntries(db, r)
+			if err == nil {
+				fmt.Println("Database:\t", fmt.Sprintf("ok, apps tracked today: %d", len(entries)))
+			}
+		}
+		if err != nil {
+			fmt.Println("Database:\t", err)
+		}
+	},
+}
+
+// doctorSample prints what the backend currently reports
+func doctorSample(backend string) {
+	o, err := system.Open(backend)
+	if err != nil {
+		fmt.Println("Sample:\t\t", err)
+		return
+	}
+	if c, ok := o.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	sample, err := o.Sample()
+	if err != nil {
+		fmt.Println("Sample:\t\t", err)
+	} else {
+		fmt.Println("Sample app:\t", sample.AppName())
+		fmt.Println("Sample title:\t", sample.Title)
+		if sample.PID != 0 {
+			fmt.Println("Sample process:\t", sample.PID, sample.Exe)
+		}
+	}
+
+	idle, err := o.IdleTime()
+	if errors.Is(err, system.ErrIdleTimeUnsupported) {
+		fmt.Println("Idle time:\t", "not supported, idle time is not tracked")
+	} else if err != nil {
+		fmt.Println("Idle time:\t", err)
+	} else {
+		fmt.Println("Idle time:\t", report.FormatDuration(idle))
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+}
@@ -19,54 +19,118 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"os/exec"
 	"sort"
 	"time"
 
+	"github.com/shldhll/hourglass/control"
 	"github.com/shldhll/hourglass/data"
-	"github.com/shldhll/hourglass/tracker"
+	"github.com/shldhll/hourglass/report"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 // logsCmd represents the logs command
 var logsCmd = &cobra.Command{
 	Use:   "logs",
 	Short: "Logs of current day",
+	Long:  "Logs of current day, or of the total per application over the days selected with --from and --to",
 	Run: func(cmd *cobra.Command, args []string) {
-		_, err := exec.Command("mkdir", "-p", os.Getenv("HOME")+"/.hourglass").Output()
+		r, err := rangeFromFlags(cmd)
 		if err != nil {
 			log.Fatal(err)
 			return
 		}
-		db, err := data.GetBadgerDB(os.Getenv("HOME")+"/.hourglass/data", data.BadgerDBUtilsDefault{})
+		groupBy, err := groupByFromFlags(cmd)
 		if err != nil {
 			log.Fatal(err)
 			return
 		}
-		today := time.Now()
-		entries, err := db.ReadList(today.Format(tracker.EntryIDDateFormat))
+		entries, err := logEntries(r, groupBy)
 		if err != nil {
 			log.Fatal(err)
 			return
 		}
+		entries = sumByApp(entries)
 		sort.Slice(entries[:], func(i, j int) bool {
 			return entries[i].Duration > entries[j].Duration
 		})
 		for i, entry := range entries {
 			fmt.Println(i+1, ")")
-			fmt.Print("Name: \t\t", entry.AppName)
-			duration := entry.Duration.Round(time.Second)
-			h := duration / time.Hour
-			duration -= h * time.Hour
-			m := duration / time.Minute
-			duration -= m * time.Minute
-			s := duration / time.Second
-			fmt.Println("Duration:\t", fmt.Sprintf("%02d:%02d:%02d", h, m, s))
+			fmt.Println("Name: \t\t", entry.AppName)
+			fmt.Println("Duration:\t", report.FormatDuration(entry.Duration))
 			fmt.Println()
 		}
 	},
 }
 
+// logEntries returns the entries of the given range, summed up from the
+// sessions in the zone of the range and grouped by groupBy. When grouped by
+// report.GroupByApp and every session was recorded in that zone, the stored
+// entries are used instead. Entries of the current day in the current zone
+// are requested from the running tracker, if there is one, so the database
+// does not have to be opened. Memory storage can only be read that way.
+func logEntries(r report.Range, groupBy string) ([]data.Entry, error) {
+	dir := os.Getenv("HOME") + "/.hourglass"
+	start, err := dayStart(viper.GetViper())
+	if err != nil {
+		return nil, err
+	}
+	driver, _ := storage()
+	today := data.Day(time.Now(), start).Format(report.DateFormat)
+	if groupBy == report.GroupByApp && r.From.Location() == time.Local && r.From.Format(report.DateFormat) == today && r.To.Format(report.DateFormat) == today {
+		resp, err := control.Send(dir+"/"+control.SocketFileName, control.CommandToday)
+		if err == nil {
+			return resp.Entries, nil
+		}
+		if driver == data.DriverMemory {
+			return nil, fmt.Errorf("%s: %v", data.ErrMemoryReadOnlyText, err)
+		}
+	}
+
+	db, err := openReadOnlyDB()
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	sessions, err := readSessions(db, r)
+	if err != nil {
+		return nil, err
+	}
+	if groupBy == report.GroupByApp && recordedInZone(sessions, r.From.Location()) {
+		return readEntries(db, r)
+	}
+
+	rows, err := report.RowsFromSessions(sessions, groupBy, r.DayStart)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]data.Entry, 0, len(rows))
+	for _, row := range rows {
+		entries = append(entries, data.Entry{AppName: row.AppName, Duration: row.Duration})
+	}
+	return entries, nil
+}
+
+// sumByApp merges entries of the same application recorded on different days
+func sumByApp(entries []data.Entry) []data.Entry {
+	sums := make([]data.Entry, 0, len(entries))
+	index := make(map[string]int)
+	for _, entry := range entries {
+		i, ok := index[entry.AppName]
+		if !ok {
+			index[entry.AppName] = len(sums)
+			sums = append(sums, entry)
+			continue
+		}
+		sums[i].Duration += entry.Duration
+	}
+	return sums
+}
+
 func init() {
 	rootCmd.AddCommand(logsCmd)
+	addRangeFlags(logsCmd)
+	addGroupByFlag(logsCmd)
 }
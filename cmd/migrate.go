@@ -0,0 +1,78 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shldhll/hourglass/daemon"
+	"github.com/shldhll/hourglass/data"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the database to the current schema version",
+	Long:  "Upgrade the database to the current schema version, backing it up first. The tracker migrates the database when it starts, so this is only needed to migrate ahead of time or to see which migrations are pending with --dry-run.",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := os.Getenv("HOME") + "/.hourglass"
+		if state, err := daemon.Status(dir + "/" + daemon.PIDFileName); err == nil {
+			fmt.Printf("tracker is running (pid %d), stop it first with: hourglass stop\n", state.PID)
+			return
+		}
+		driver, location := storage()
+		if _, err := os.Stat(location); err != nil && driver != data.DriverMemory {
+			println(errNoData.Error())
+			return
+		}
+
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		result, err := data.Migrate(driver, location, dryRun)
+		printMigration(result, dryRun)
+		if err != nil {
+			println("migration error:", err.Error())
+		}
+	},
+}
+
+// printMigration prints the migrations of the result and where the database
+// was backed up to
+func printMigration(result data.MigrationResult, dryRun bool) {
+	if len(result.Applied) == 0 {
+		fmt.Printf("database is up to date (schema version %d)\n", result.To)
+		return
+	}
+
+	if result.BackupFile != "" {
+		fmt.Println("database backed up to", result.BackupFile)
+	}
+	for _, m := range result.Applied {
+		switch {
+		case dryRun:
+			fmt.Printf("pending: v%d %s\n", m.Version, m.Description)
+		case m.Version <= result.To:
+			fmt.Printf("migrated: v%d %s\n", m.Version, m.Description)
+		}
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(migrateCmd)
+
+	migrateCmd.Flags().Bool("dry-run", false, "only list the pending migrations")
+}
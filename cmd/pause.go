@@ -0,0 +1,60 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shldhll/hourglass/control"
+	"github.com/spf13/cobra"
+)
+
+// pauseCmd represents the pause command
+var pauseCmd = &cobra.Command{
+	Use:   "pause [duration]",
+	Short: "Pause tracking, optionally for a duration such as 30m",
+	Args:  cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var duration time.Duration
+		if len(args) == 1 {
+			var err error
+			duration, err = time.ParseDuration(args[0])
+			if err != nil || duration <= 0 {
+				println("usage: hourglass pause [duration], e.g. hourglass pause 30m")
+				return
+			}
+		}
+
+		req := control.Request{Command: control.CommandPause, Duration: duration}
+		_, err := control.SendRequest(os.Getenv("HOME")+"/.hourglass/"+control.SocketFileName, req)
+		if err != nil {
+			println("could not reach the tracker:", err.Error())
+			return
+		}
+
+		if duration == 0 {
+			fmt.Println("paused tracking, resume with: hourglass resume")
+			return
+		}
+		fmt.Println("paused tracking until", time.Now().Add(duration).Format("15:04:05"))
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+}
@@ -0,0 +1,42 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/shldhll/hourglass/control"
+	"github.com/spf13/cobra"
+)
+
+// resumeCmd represents the resume command
+var resumeCmd = &cobra.Command{
+	Use:   "resume",
+	Short: "Resume paused tracking",
+	Run: func(cmd *cobra.Command, args []string) {
+		_, err := control.Send(os.Getenv("HOME")+"/.hourglass/"+control.SocketFileName, control.CommandResume)
+		if err != nil {
+			println("could not reach the tracker:", err.Error())
+			return
+		}
+		fmt.Println("resumed tracking")
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
@@ -20,6 +20,7 @@ import (
 	"os"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	homedir "github.com/mitchellh/go-homedir"
 	"github.com/spf13/viper"
@@ -27,6 +28,13 @@ import (
 
 var cfgFile string
 
+// configDefaults and flagBindings hold the defaults and flags of the config
+// keys, so that a fresh viper instance can be set up like the global one
+var (
+	configDefaults = make(map[string]interface{})
+	flagBindings   = make(map[string]*pflag.Flag)
+)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "hourglass",
@@ -51,6 +59,8 @@ func init() {
 	// will be global for your application.
 
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is $HOME/.hourglass.yaml)")
+	rootCmd.PersistentFlags().Duration("day-start", 0, "time after midnight at which a day starts, e.g. 4h to credit work until 04:00 to the previous day")
+	bindFlag("day_start", rootCmd.PersistentFlags().Lookup("day-start"))
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -59,18 +69,7 @@ func init() {
 
 // initConfig reads in config file and ENV variables if set.
 func initConfig() {
-	if cfgFile != "" {
-		// Use config file from the flag.
-		viper.SetConfigFile(cfgFile)
-	} else {
-		// Find home directory.
-		home, err := homedir.Dir()
-		cobra.CheckErr(err)
-
-		// Search config in home directory with name ".hourglass" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigName(".hourglass_config")
-	}
+	cobra.CheckErr(setConfigFile(viper.GetViper()))
 
 	viper.AutomaticEnv() // read in environment variables that match
 
@@ -79,3 +78,62 @@ func initConfig() {
 		fmt.Fprintln(os.Stderr, "Using config file:", viper.ConfigFileUsed())
 	}
 }
+
+// setConfigFile points v at the config file given with --config or, by
+// default, the one in the home directory
+func setConfigFile(v *viper.Viper) error {
+	if cfgFile != "" {
+		// Use config file from the flag.
+		v.SetConfigFile(cfgFile)
+		return nil
+	}
+
+	// Find home directory.
+	home, err := homedir.Dir()
+	if err != nil {
+		return err
+	}
+
+	// Search config in home directory with name ".hourglass" (without extension).
+	v.AddConfigPath(home)
+	v.SetConfigName(".hourglass_config")
+	return nil
+}
+
+// readConfig reads the config file and environment into a new viper
+// instance with the defaults and flags of the global one, which is left
+// untouched. A missing config file is not an error.
+func readConfig() (*viper.Viper, error) {
+	v := viper.New()
+	for key, value := range configDefaults {
+		v.SetDefault(key, value)
+	}
+	for key, flag := range flagBindings {
+		if err := v.BindPFlag(key, flag); err != nil {
+			return nil, err
+		}
+	}
+	if err := setConfigFile(v); err != nil {
+		return nil, err
+	}
+	v.AutomaticEnv()
+
+	if err := v.ReadInConfig(); err != nil {
+		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// setDefault sets the default value of the config key
+func setDefault(key string, value interface{}) {
+	viper.SetDefault(key, value)
+	configDefaults[key] = value
+}
+
+// bindFlag binds the config key to the given flag
+func bindFlag(key string, flag *pflag.Flag) {
+	cobra.CheckErr(viper.BindPFlag(key, flag))
+	flagBindings[key] = flag
+}
@@ -16,36 +16,172 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
 	"os/exec"
+	"os/signal"
+	"strings"
+	"syscall"
 	"time"
 
+	"github.com/godbus/dbus/v5"
+	"github.com/shldhll/hourglass/control"
+	"github.com/shldhll/hourglass/daemon"
 	"github.com/shldhll/hourglass/data"
 	"github.com/shldhll/hourglass/system"
 	"github.com/shldhll/hourglass/tracker"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// detachWaitTime is how long start --detach waits for the tracker to come up
+const detachWaitTime = 5 * time.Second
+
 // startCmd represents the start command
 var startCmd = &cobra.Command{
 	Use:   "start",
 	Short: "Start the time tracker",
 	Run: func(cmd *cobra.Command, args []string) {
-		_, err := exec.Command("mkdir", "-p", os.Getenv("HOME")+"/.hourglass").Output()
+		dir := os.Getenv("HOME") + "/.hourglass"
+		_, err := exec.Command("mkdir", "-p", dir).Output()
 		if err != nil {
 			println(err.Error())
 			return
 		}
-		db, err := data.GetBadgerDB(os.Getenv("HOME")+"/.hourglass/data", data.BadgerDBUtilsDefault{})
+
+		pidPath := dir + "/" + daemon.PIDFileName
+		if detach, _ := cmd.Flags().GetBool("detach"); detach {
+			pid, err := daemon.Detach(detachedArgs(os.Args[1:]), dir+"/"+daemon.LogFileName)
+			if err != nil {
+				println("error occured:", err.Error())
+				return
+			}
+			if _, err = daemon.WaitRunning(pidPath, pid, detachWaitTime); err != nil {
+				println("tracker did not start:", err.Error())
+				println("see", dir+"/"+daemon.LogFileName)
+				return
+			}
+			fmt.Printf("started tracking in background (pid %d)\n", pid)
+			return
+		}
+
+		config, err := loadConfig(viper.GetViper())
+		if err != nil {
+			println("error occured:", err.Error())
+			return
+		}
+
+		driver, location := storage()
+		lock, err := daemon.Acquire(pidPath, location)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		defer lock.Release()
+
+		backend := viper.GetString("backend")
+		o, err := system.Open(backend)
+		if o == nil {
+			println("backend error:", err.Error())
+			return
+		}
+		if err != nil {
+			println("falling back to xprop:", err.Error())
+		}
+		if c, ok := o.(io.Closer); ok {
+			defer c.Close()
+		}
+
+		migration, err := data.Migrate(driver, location, false)
+		if len(migration.Applied) > 0 {
+			printMigration(migration, false)
+		}
+		if err != nil {
+			println("db error:", err.Error())
+			return
+		}
+
+		db, err := data.Open(driver, location)
 		if err != nil {
-			println("db error:", err)
+			println("db error:", err.Error())
 			return
 		}
+		if driver == data.DriverMemory {
+			println("the memory storage driver keeps the tracking data only until the tracker stops")
+		}
+		status := tracker.NewStatus(func(appName string, since time.Time) {
+			if err := lock.SetCurrent(appName, since); err != nil {
+				println("error occured:", err.Error())
+			}
+		})
+		cfg := system.NewSharedConfig(config)
+
+		pausePath := dir + "/" + daemon.PauseFileName
+		pause, err := daemon.LoadPause(pausePath)
+		if err != nil {
+			println("error occured:", err.Error())
+		}
+		if pause.Active(time.Now()) {
+			status.Pause(pause.Until)
+			println("tracking is paused, resume with: hourglass resume")
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		handler := trackerHandler{status: status, db: db, cfg: cfg, pausePath: pausePath}
+		go func() {
+			if err := control.Serve(ctx, dir+"/"+control.SocketFileName, handler); err != nil {
+				println("control socket error:", err.Error())
+			}
+		}()
+
+		if viper.GetBool("logind") {
+			if bus, err := dbus.ConnectSystemBus(); err != nil {
+				println("sleep and screen locks are not tracked:", err.Error())
+			} else {
+				defer bus.Close()
+				if err = system.WatchLogind(ctx, bus, status); err != nil {
+					println(err.Error())
+				}
+			}
+		}
+
 		println("started tracking...")
-		tracker.Start(system.Current{}, db, system.GetConfig(1*time.Second, 1*time.Second))
+		tracker.Start(ctx, o, db, cfg, status)
+
+		if err = db.Close(); err != nil {
+			println("db error:", err.Error())
+			return
+		}
+		println("stopped tracking")
 	},
 }
 
+// detachedArgs returns the given command line without the detach flag
+func detachedArgs(args []string) []string {
+	result := make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "-d" || arg == "--detach" || strings.HasPrefix(arg, "--detach=") {
+			continue
+		}
+		result = append(result, arg)
+	}
+	return result
+}
+
 func init() {
 	rootCmd.AddCommand(startCmd)
+
+	startCmd.Flags().BoolP("detach", "d", false, "run the tracker in the background")
+	setDefault("cooldown", 1*time.Second)
+	setDefault("min_usage", 1*time.Second)
+	startCmd.Flags().Duration("idle-threshold", 5*time.Minute, "time without input after which you are considered idle (0 disables)")
+	bindFlag("idle_threshold", startCmd.Flags().Lookup("idle-threshold"))
+	startCmd.Flags().Bool("logind", true, "record sleep and screen locks reported by logind")
+	bindFlag("logind", startCmd.Flags().Lookup("logind"))
+	startCmd.Flags().String("backend", system.BackendAuto, "window information backend: "+strings.Join(system.Backends, ", "))
+	bindFlag("backend", startCmd.Flags().Lookup("backend"))
 }
@@ -0,0 +1,65 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shldhll/hourglass/control"
+	"github.com/shldhll/hourglass/daemon"
+	"github.com/shldhll/hourglass/report"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the time tracker is running",
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := os.Getenv("HOME") + "/.hourglass"
+		state, err := daemon.Status(dir + "/" + daemon.PIDFileName)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+
+		tracking := "yes"
+		if resp, err := control.Send(dir+"/"+control.SocketFileName, control.CommandCurrent); err == nil {
+			state.CurrentApp = resp.Current.AppName
+			state.CurrentSince = resp.Current.Since
+			if resp.Current.Paused {
+				tracking = "paused"
+				if !resp.Current.PausedUntil.IsZero() {
+					tracking += " until " + resp.Current.PausedUntil.Format("15:04:05")
+				}
+			}
+		}
+
+		now := time.Now()
+		fmt.Println("Tracking:\t", tracking)
+		fmt.Println("PID:\t\t", state.PID)
+		fmt.Println("Since:\t\t", state.Started.Format(time.RFC1123), "("+report.FormatDuration(now.Sub(state.Started))+")")
+		if !state.CurrentSince.IsZero() {
+			fmt.Println("Current app:\t", state.CurrentApp, "("+report.FormatDuration(now.Sub(state.CurrentSince))+")")
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+}
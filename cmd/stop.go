@@ -0,0 +1,46 @@
+/*
+Copyright © 2021 NAME HERE <EMAIL ADDRESS>
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/shldhll/hourglass/daemon"
+	"github.com/spf13/cobra"
+)
+
+// stopWaitTime is how long stop waits for the tracker to exit
+const stopWaitTime = 10 * time.Second
+
+// stopCmd represents the stop command
+var stopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running time tracker",
+	Run: func(cmd *cobra.Command, args []string) {
+		state, err := daemon.Stop(os.Getenv("HOME")+"/.hourglass/"+daemon.PIDFileName, stopWaitTime)
+		if err != nil {
+			println(err.Error())
+			return
+		}
+		fmt.Printf("stopped tracking (pid %d)\n", state.PID)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+}
@@ -0,0 +1,198 @@
+package control
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+)
+
+const (
+	// SocketFileName is the name of the socket inside the hourglass directory
+	SocketFileName = "hourglass.sock"
+
+	// CommandPause pauses tracking
+	CommandPause = "pause"
+	// CommandResume resumes tracking
+	CommandResume = "resume"
+	// CommandCurrent returns the application currently tracked
+	CommandCurrent = "current"
+	// CommandToday returns the entries of the current day
+	CommandToday = "today"
+	// CommandReloadConfig reloads the configuration file
+	CommandReloadConfig = "reload-config"
+
+	// ErrUnknownCommandText is used as prefix text for unknown commands
+	ErrUnknownCommandText = "unknown command"
+
+	requestTimeout = 5 * time.Second
+)
+
+// ErrAlreadyServing is returned when another process serves the socket
+var ErrAlreadyServing = errors.New("control socket is already being served")
+
+// Request represents a single command sent to the tracker. Duration is only
+// used by CommandPause, where zero pauses until CommandResume is sent.
+type Request struct {
+	Command  string        `json:"command"`
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Current describes what the tracker is doing. A zero PausedUntil means
+// tracking is paused until it is resumed.
+type Current struct {
+	AppName     string    `json:"app"`
+	Since       time.Time `json:"since"`
+	Paused      bool      `json:"paused"`
+	PausedUntil time.Time `json:"paused_until,omitempty"`
+}
+
+// Response represents the answer of the tracker to a request
+type Response struct {
+	OK      bool         `json:"ok"`
+	Error   string       `json:"error,omitempty"`
+	Current *Current     `json:"current,omitempty"`
+	Entries []data.Entry `json:"entries,omitempty"`
+}
+
+// Handler executes the commands received over the socket
+type Handler interface {
+	Pause(duration time.Duration) error
+	Resume() error
+	Current() (Current, error)
+	Today() ([]data.Entry, error)
+	ReloadConfig() error
+}
+
+// Serve listens on the unix socket at path and answers requests using h
+// until ctx is cancelled. The socket file is removed on return.
+func Serve(ctx context.Context, path string, h Handler) error {
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return ErrAlreadyServing
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(path)
+
+	if err = os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		listener.Close()
+	}()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+		go serveConn(conn, h)
+	}
+}
+
+// serveConn answers the requests on a single connection, one per line
+func serveConn(conn net.Conn, h Handler) {
+	defer conn.Close()
+
+	scanner := bufio.NewScanner(conn)
+	encoder := json.NewEncoder(conn)
+	for scanner.Scan() {
+		var req Request
+		var resp Response
+
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp = Handle(h, req)
+		}
+
+		if err := encoder.Encode(resp); err != nil {
+			return
+		}
+	}
+}
+
+// Handle executes a single request using h
+func Handle(h Handler, req Request) Response {
+	var resp Response
+	var err error
+
+	switch req.Command {
+	case CommandPause:
+		err = h.Pause(req.Duration)
+	case CommandResume:
+		err = h.Resume()
+	case CommandCurrent:
+		var current Current
+		current, err = h.Current()
+		resp.Current = &current
+	case CommandToday:
+		resp.Entries, err = h.Today()
+	case CommandReloadConfig:
+		err = h.ReloadConfig()
+	default:
+		err = fmt.Errorf("%s: %q", ErrUnknownCommandText, req.Command)
+	}
+
+	if err != nil {
+		resp.Error = err.Error()
+		return resp
+	}
+	resp.OK = true
+	return resp
+}
+
+// Send sends the command to the tracker serving the socket at path and
+// returns its response. A response reporting a failure is returned together
+// with an error.
+func Send(path string, command string) (Response, error) {
+	return SendRequest(path, Request{Command: command})
+}
+
+// SendRequest sends the request to the tracker serving the socket at path
+// and returns its response, like Send
+func SendRequest(path string, req Request) (Response, error) {
+	var resp Response
+
+	conn, err := net.DialTimeout("unix", path, requestTimeout)
+	if err != nil {
+		return resp, err
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(requestTimeout)); err != nil {
+		return resp, err
+	}
+
+	if err = json.NewEncoder(conn).Encode(req); err != nil {
+		return resp, err
+	}
+
+	if err = json.NewDecoder(conn).Decode(&resp); err != nil {
+		return resp, err
+	}
+
+	if !resp.OK {
+		return resp, errors.New(resp.Error)
+	}
+	return resp, nil
+}
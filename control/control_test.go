@@ -0,0 +1,222 @@
+package control_test
+
+import (
+	"github.com/shldhll/hourglass/control"
+	"github.com/shldhll/hourglass/data"
+
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const stubName = "App Name"
+
+var (
+	stubTime      = time.Date(1970, 01, 01, 0, 0, 0, 0, time.UTC)
+	stubReloadErr = errors.New("Error occurred while reloading")
+)
+
+type stubHandler struct {
+	mu        sync.Mutex
+	paused    bool
+	until     time.Time
+	reloadErr error
+}
+
+func (s *stubHandler) Pause(duration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = true
+	if duration != 0 {
+		s.until = stubTime.Add(duration)
+	}
+	return nil
+}
+
+func (s *stubHandler) Resume() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.paused = false
+	return nil
+}
+
+func (s *stubHandler) Current() (control.Current, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return control.Current{AppName: stubName, Since: stubTime, Paused: s.paused, PausedUntil: s.until}, nil
+}
+
+func (s *stubHandler) Today() ([]data.Entry, error) {
+	return []data.Entry{{ID: "1970-01-01_AppName", AppName: stubName, Duration: time.Hour}}, nil
+}
+
+func (s *stubHandler) ReloadConfig() error {
+	return s.reloadErr
+}
+
+// serve starts serving h on a socket in a temporary directory
+func serve(t *testing.T, h control.Handler) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), control.SocketFileName)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+
+	go func() {
+		done <- control.Serve(ctx, path, h)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		if err := <-done; err != nil {
+			t.Errorf("No error expected, got %v", err)
+		}
+	})
+
+	for i := 0; i < 100; i++ {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("socket not created")
+	return path
+}
+
+func TestServe(t *testing.T) {
+	t.Run("Pause and resume", func(t *testing.T) {
+		path := serve(t, &stubHandler{})
+
+		_, err := control.Send(path, control.CommandPause)
+		assertErrorFatal(t, err)
+		resp, err := control.Send(path, control.CommandCurrent)
+		assertErrorFatal(t, err)
+		if !resp.Current.Paused {
+			t.Error("tracker not paused")
+		}
+
+		_, err = control.Send(path, control.CommandResume)
+		assertErrorFatal(t, err)
+		resp, err = control.Send(path, control.CommandCurrent)
+		assertErrorFatal(t, err)
+		if resp.Current.Paused {
+			t.Error("tracker not resumed")
+		}
+	})
+
+	t.Run("Pause with duration", func(t *testing.T) {
+		path := serve(t, &stubHandler{})
+
+		_, err := control.SendRequest(path, control.Request{Command: control.CommandPause, Duration: time.Hour})
+		assertErrorFatal(t, err)
+		resp, err := control.Send(path, control.CommandCurrent)
+		assertErrorFatal(t, err)
+
+		if want := stubTime.Add(time.Hour); !resp.Current.Paused || !resp.Current.PausedUntil.Equal(want) {
+			t.Errorf("got %+v, want paused until %v", *resp.Current, want)
+		}
+	})
+
+	t.Run("Current", func(t *testing.T) {
+		path := serve(t, &stubHandler{})
+
+		resp, err := control.Send(path, control.CommandCurrent)
+		assertErrorFatal(t, err)
+
+		want := control.Current{AppName: stubName, Since: stubTime}
+		if !reflect.DeepEqual(*resp.Current, want) {
+			t.Errorf("got %v, want %v", *resp.Current, want)
+		}
+	})
+
+	t.Run("Today", func(t *testing.T) {
+		h := &stubHandler{}
+		path := serve(t, h)
+
+		resp, err := control.Send(path, control.CommandToday)
+		assertErrorFatal(t, err)
+
+		want, _ := h.Today()
+		if !reflect.DeepEqual(resp.Entries, want) {
+			t.Errorf("got %v, want %v", resp.Entries, want)
+		}
+	})
+
+	t.Run("Reload config error", func(t *testing.T) {
+		path := serve(t, &stubHandler{reloadErr: stubReloadErr})
+
+		resp, err := control.Send(path, control.CommandReloadConfig)
+		if err == nil || err.Error() != stubReloadErr.Error() {
+			t.Errorf("got %v, want %v", err, stubReloadErr)
+		}
+		if resp.OK {
+			t.Error("response reported success")
+		}
+	})
+
+	t.Run("Unknown command", func(t *testing.T) {
+		path := serve(t, &stubHandler{})
+
+		_, err := control.Send(path, "explode")
+		if err == nil || !strings.HasPrefix(err.Error(), control.ErrUnknownCommandText) {
+			t.Errorf("got %v, want %q error", err, control.ErrUnknownCommandText)
+		}
+	})
+
+	t.Run("Socket already served", func(t *testing.T) {
+		path := serve(t, &stubHandler{})
+
+		err := control.Serve(context.Background(), path, &stubHandler{})
+		if err != control.ErrAlreadyServing {
+			t.Errorf("got %v, want %v", err, control.ErrAlreadyServing)
+		}
+	})
+
+	t.Run("Stale socket replaced", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), control.SocketFileName)
+		err := os.WriteFile(path, nil, 0600)
+		assertErrorFatal(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error, 1)
+		go func() {
+			done <- control.Serve(ctx, path, &stubHandler{})
+		}()
+
+		var resp control.Response
+		for i := 0; i < 100; i++ {
+			if resp, err = control.Send(path, control.CommandCurrent); err == nil {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		assertErrorFatal(t, err)
+		if resp.Current.AppName != stubName {
+			t.Errorf("got %q, want %q", resp.Current.AppName, stubName)
+		}
+
+		cancel()
+		assertErrorFatal(t, <-done)
+		if _, err = os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("socket not removed, got %v", err)
+		}
+	})
+}
+
+func TestSend(t *testing.T) {
+	_, err := control.Send(filepath.Join(t.TempDir(), control.SocketFileName), control.CommandCurrent)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func assertErrorFatal(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+}
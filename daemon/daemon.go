@@ -0,0 +1,233 @@
+package daemon
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	// PIDFileName is the name of the PID file inside the hourglass directory
+	PIDFileName = "hourglass.pid"
+	// LogFileName is the name of the log file written by a detached tracker
+	LogFileName = "hourglass.log"
+	// DetachedEnv is set in the environment of a detached tracker
+	DetachedEnv = "HOURGLASS_DETACHED"
+
+	pollInterval = 100 * time.Millisecond
+)
+
+var (
+	// ErrAlreadyRunning is returned when another tracker holds the PID file
+	ErrAlreadyRunning = errors.New("hourglass is already tracking")
+	// ErrNotRunning is returned when no tracker holds the PID file
+	ErrNotRunning = errors.New("hourglass is not tracking")
+	// ErrStopTimeout is returned when a tracker does not exit in time
+	ErrStopTimeout = errors.New("hourglass did not stop in time")
+)
+
+// State describes a running tracker. It is stored in the PID file.
+type State struct {
+	PID          int       `json:"pid"`
+	Started      time.Time `json:"started"`
+	DataDir      string    `json:"data_dir"`
+	CurrentApp   string    `json:"current_app"`
+	CurrentSince time.Time `json:"current_since"`
+}
+
+// Lock represents the PID file held by the running tracker
+type Lock struct {
+	mu    sync.Mutex
+	file  *os.File
+	state State
+}
+
+// Acquire locks the PID file at path for the tracker writing to dataDir. It
+// returns ErrAlreadyRunning if another tracker holds the lock.
+func Acquire(path, dataDir string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = lockFile(f); err != nil {
+		state, readErr := readState(f)
+		f.Close()
+		if readErr == nil && state.PID != 0 {
+			return nil, fmt.Errorf("%w (pid %d, data %s)", ErrAlreadyRunning, state.PID, state.DataDir)
+		}
+		return nil, ErrAlreadyRunning
+	}
+
+	l := &Lock{
+		file: f,
+		state: State{
+			PID:     os.Getpid(),
+			Started: time.Now(),
+			DataDir: dataDir,
+		},
+	}
+
+	if err = l.write(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// SetCurrent records the application the tracker is currently crediting
+func (l *Lock) SetCurrent(appName string, since time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.state.CurrentApp = appName
+	l.state.CurrentSince = since
+	return l.write()
+}
+
+// State returns the state stored in the PID file
+func (l *Lock) State() State {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.state
+}
+
+// Release empties and unlocks the PID file
+func (l *Lock) Release() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	err := l.file.Truncate(0)
+	if closeErr := l.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// write replaces the content of the PID file with the current state. The
+// state is written before the file is cut to its length, so the file is
+// never empty while the lock is held.
+func (l *Lock) write() error {
+	value, err := json.Marshal(l.state)
+	if err != nil {
+		return err
+	}
+
+	if _, err = l.file.WriteAt(value, 0); err != nil {
+		return err
+	}
+	return l.file.Truncate(int64(len(value)))
+}
+
+// Status returns the state of the tracker holding the PID file at path. It
+// returns ErrNotRunning if no tracker holds the lock.
+func Status(path string) (State, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return State{}, ErrNotRunning
+	}
+	if err != nil {
+		return State{}, err
+	}
+	defer f.Close()
+
+	if isLocked(f) {
+		return readState(f)
+	}
+	return State{}, ErrNotRunning
+}
+
+// Detach starts the current executable with the given arguments in a new
+// session, appending its output to the file at logPath, and returns the PID
+// of the new process
+func Detach(args []string, logPath string) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	logFile, err := os.OpenFile(logPath, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, err
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.Env = append(os.Environ(), DetachedEnv+"=1")
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachedAttr()
+
+	if err = cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	pid := cmd.Process.Pid
+	return pid, cmd.Process.Release()
+}
+
+// WaitRunning waits until the process with the given pid holds the PID file
+// at path
+func WaitRunning(path string, pid int, timeout time.Duration) (State, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		state, err := Status(path)
+		if err == nil && state.PID == pid {
+			return state, nil
+		}
+		if !processAlive(pid) || time.Now().After(deadline) {
+			if err == nil {
+				err = ErrNotRunning
+			}
+			return state, err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Stop asks the tracker holding the PID file at path to exit and waits until
+// it released the lock
+func Stop(path string, timeout time.Duration) (State, error) {
+	state, err := Status(path)
+	if err != nil {
+		return state, err
+	}
+
+	if err = terminate(state.PID); err != nil {
+		return state, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if _, err = Status(path); errors.Is(err, ErrNotRunning) {
+			return state, nil
+		}
+		time.Sleep(pollInterval)
+	}
+	return state, ErrStopTimeout
+}
+
+// readState decodes the state stored in the given PID file. Anything after
+// the state, left over from a longer one until the file is cut, is ignored.
+func readState(f *os.File) (State, error) {
+	var state State
+
+	value, err := ioutil.ReadAll(io.NewSectionReader(f, 0, 1<<20))
+	if err != nil {
+		return state, err
+	}
+	if len(value) == 0 {
+		return state, nil
+	}
+
+	err = json.NewDecoder(bytes.NewReader(value)).Decode(&state)
+	return state, err
+}
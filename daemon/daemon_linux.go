@@ -0,0 +1,38 @@
+package daemon
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile takes an exclusive lock on f without blocking
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+}
+
+// isLocked reports whether another open file holds an exclusive lock on f
+func isLocked(f *os.File) bool {
+	err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB)
+	if err != nil {
+		return err == syscall.EWOULDBLOCK
+	}
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return false
+}
+
+// processAlive reports whether a process with the given pid exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
+
+// terminate asks the process with the given pid to exit
+func terminate(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// detachedAttr starts a process in its own session, so it is not affected by
+// the terminal it was started from
+func detachedAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
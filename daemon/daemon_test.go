@@ -0,0 +1,174 @@
+package daemon_test
+
+import (
+	"github.com/shldhll/hourglass/daemon"
+
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const (
+	stubDataDir = "/tmp/hourglass/data"
+	stubName    = "App Name"
+)
+
+var stubTime = time.Date(1970, 01, 01, 0, 0, 0, 0, time.UTC)
+
+func pidPath(t *testing.T) string {
+	t.Helper()
+	return filepath.Join(t.TempDir(), daemon.PIDFileName)
+}
+
+func TestAcquire(t *testing.T) {
+	t.Run("Acquire and release", func(t *testing.T) {
+		path := pidPath(t)
+		lock, err := daemon.Acquire(path, stubDataDir)
+		assertErrorFatal(t, err)
+
+		state := lock.State()
+		if state.PID != os.Getpid() || state.DataDir != stubDataDir {
+			t.Errorf("got %+v, want pid %d and data dir %q", state, os.Getpid(), stubDataDir)
+		}
+
+		err = lock.Release()
+		assertErrorFatal(t, err)
+
+		lock, err = daemon.Acquire(path, stubDataDir)
+		assertErrorFatal(t, err)
+		lock.Release()
+	})
+
+	t.Run("Second tracker refused", func(t *testing.T) {
+		path := pidPath(t)
+		lock, err := daemon.Acquire(path, stubDataDir)
+		assertErrorFatal(t, err)
+		defer lock.Release()
+
+		_, err = daemon.Acquire(path, stubDataDir)
+		if !errors.Is(err, daemon.ErrAlreadyRunning) {
+			t.Errorf("got %v, want %v", err, daemon.ErrAlreadyRunning)
+		}
+	})
+}
+
+func TestStatus(t *testing.T) {
+	t.Run("No PID file", func(t *testing.T) {
+		_, err := daemon.Status(pidPath(t))
+		if !errors.Is(err, daemon.ErrNotRunning) {
+			t.Errorf("got %v, want %v", err, daemon.ErrNotRunning)
+		}
+	})
+
+	t.Run("Running tracker", func(t *testing.T) {
+		path := pidPath(t)
+		lock, err := daemon.Acquire(path, stubDataDir)
+		assertErrorFatal(t, err)
+		defer lock.Release()
+
+		err = lock.SetCurrent(stubName, stubTime)
+		assertErrorFatal(t, err)
+
+		state, err := daemon.Status(path)
+		assertErrorFatal(t, err)
+
+		if state.PID != os.Getpid() {
+			t.Errorf("got %d, want %d", state.PID, os.Getpid())
+		}
+		if state.CurrentApp != stubName || !state.CurrentSince.Equal(stubTime) {
+			t.Errorf("got %q since %v, want %q since %v", state.CurrentApp, state.CurrentSince, stubName, stubTime)
+		}
+	})
+
+	t.Run("Shorter state", func(t *testing.T) {
+		path := pidPath(t)
+		lock, err := daemon.Acquire(path, stubDataDir)
+		assertErrorFatal(t, err)
+		defer lock.Release()
+
+		err = lock.SetCurrent(stubName+" with a longer name", stubTime)
+		assertErrorFatal(t, err)
+		err = lock.SetCurrent(stubName, stubTime)
+		assertErrorFatal(t, err)
+
+		state, err := daemon.Status(path)
+		assertErrorFatal(t, err)
+		if state.CurrentApp != stubName {
+			t.Errorf("got %q, want %q", state.CurrentApp, stubName)
+		}
+
+		value, err := ioutil.ReadFile(path)
+		assertErrorFatal(t, err)
+		var stored daemon.State
+		if err = json.Unmarshal(value, &stored); err != nil {
+			t.Errorf("PID file not cut to the state: %v", err)
+		}
+	})
+
+	t.Run("Released tracker", func(t *testing.T) {
+		path := pidPath(t)
+		lock, err := daemon.Acquire(path, stubDataDir)
+		assertErrorFatal(t, err)
+		err = lock.Release()
+		assertErrorFatal(t, err)
+
+		_, err = daemon.Status(path)
+		if !errors.Is(err, daemon.ErrNotRunning) {
+			t.Errorf("got %v, want %v", err, daemon.ErrNotRunning)
+		}
+	})
+}
+
+func TestStop(t *testing.T) {
+	_, err := daemon.Stop(pidPath(t), time.Second)
+	if !errors.Is(err, daemon.ErrNotRunning) {
+		t.Errorf("got %v, want %v", err, daemon.ErrNotRunning)
+	}
+}
+
+func assertErrorFatal(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+}
+
+func TestPause(t *testing.T) {
+	t.Run("Missing file", func(t *testing.T) {
+		state, err := daemon.LoadPause(filepath.Join(t.TempDir(), daemon.PauseFileName))
+		assertErrorFatal(t, err)
+		if state.Active(stubTime) {
+			t.Error("pause active without a pause file")
+		}
+	})
+
+	t.Run("Save and load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), daemon.PauseFileName)
+		want := daemon.PauseState{Paused: true, Until: stubTime.Add(time.Hour)}
+		err := daemon.SavePause(path, want)
+		assertErrorFatal(t, err)
+
+		got, err := daemon.LoadPause(path)
+		assertErrorFatal(t, err)
+		if got.Paused != want.Paused || !got.Until.Equal(want.Until) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		state := daemon.PauseState{Paused: true, Until: stubTime.Add(time.Hour)}
+		if !state.Active(stubTime) {
+			t.Error("pause not active before it expired")
+		}
+		if state.Active(stubTime.Add(time.Hour)) {
+			t.Error("pause active after it expired")
+		}
+		if !(daemon.PauseState{Paused: true}).Active(stubTime.AddDate(1, 0, 0)) {
+			t.Error("pause without end not active")
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package daemon
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"time"
+)
+
+// PauseFileName is the name of the file inside the hourglass directory
+// storing whether tracking is paused
+const PauseFileName = "pause.json"
+
+// PauseState describes a pause of tracking. A zero Until means tracking is
+// paused until it is resumed.
+type PauseState struct {
+	Paused bool      `json:"paused"`
+	Until  time.Time `json:"until,omitempty"`
+}
+
+// Active reports whether the pause is still in effect at the given time
+func (p PauseState) Active(now time.Time) bool {
+	return p.Paused && (p.Until.IsZero() || now.Before(p.Until))
+}
+
+// SavePause stores the pause state in the file at path
+func SavePause(path string, state PauseState) error {
+	value, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err = ioutil.WriteFile(tmpPath, value, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// LoadPause returns the pause state stored in the file at path. A missing
+// file means tracking is not paused.
+func LoadPause(path string) (PauseState, error) {
+	var state PauseState
+
+	value, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+
+	err = json.Unmarshal(value, &state)
+	return state, err
+}
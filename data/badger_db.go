@@ -2,10 +2,17 @@ package data
 
 import (
 	"bytes"
+	"encoding/binary"
 	"encoding/gob"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/dgraph-io/badger"
 )
@@ -15,38 +22,81 @@ const (
 	EntryIDDateSeparator = "_"
 	// ErrReadPrefixText is used as prefix text for read errors
 	ErrReadPrefixText = "Following errors occurred while reading the entries:"
+	// ErrReadOnlyPrefixText is used as prefix text when a database can
+	// neither be opened read-only nor through a snapshot
+	ErrReadOnlyPrefixText = "Cannot open the database for reading, it is locked by a running tracker and no snapshot could be taken:"
+	// EntryKeyPrefix prefixes the keys of all entries, which are followed by
+	// the date, EntryKeyDateSeparator and the application name, so that the
+	// entries of a day share a prefix
+	EntryKeyPrefix = "entry/"
+	// EntryKeyDateSeparator separates the date from the application name in
+	// the key of an entry
+	EntryKeyDateSeparator = "/"
+	// SessionKeyPrefix prefixes the keys of all sessions, which are followed
+	// by the start time in nanoseconds, encoded to sort in time order, and
+	// the application name
+	SessionKeyPrefix = "session/"
+
+	// MaxConflictRetries is the number of times a transaction is run before
+	// giving up on conflicts with concurrent transactions
+	MaxConflictRetries = 50
+	// ConflictBackoff is how much longer, at most, to wait before each retry
+	// of a conflicting transaction than before the previous one
+	ConflictBackoff = time.Millisecond
 )
 
 // BadgerDB represents a Badger database
 type BadgerDB struct {
-	db      *badger.DB
-	dbUtils BadgerDBUtils
+	db          *badger.DB
+	dbUtils     BadgerDBUtils
+	snapshotDir string
 }
 
-// Write writes given entry to database
-func (b BadgerDB) Write(entry Entry) error {
-	key := []byte(b.GetKey(entry))
+// AddEntry adds the duration of the given entry to the entry stored under
+// the same ID. The key of an entry lists it for its day.
+func (b BadgerDB) AddEntry(entry Entry) error {
+	return b.update(func(txn *badger.Txn) error {
+		return b.addDuration(txn, entry)
+	})
+}
+
+// update runs fn in a read-write transaction, running it again in a new
+// transaction, after a random and growing pause, when it conflicted with a
+// concurrent one
+func (b BadgerDB) update(fn func(txn *badger.Txn) error) error {
+	err := b.db.Update(fn)
+	for i := 1; i < MaxConflictRetries && err == badger.ErrConflict; i++ {
+		time.Sleep(time.Duration(rand.Int63n(int64(i) * int64(ConflictBackoff))))
+		err = b.db.Update(fn)
+	}
+	return err
+}
+
+// addDuration adds the duration of the given entry to the entry stored
+// under the same ID within txn
+func (b BadgerDB) addDuration(txn *badger.Txn, entry Entry) error {
+	key := entryKey(entry.ID)
 
-	existingEntry, err := b.Read(string(key))
+	item, err := txn.Get(key)
 	if err != nil && err != badger.ErrKeyNotFound {
 		return err
 	}
-
-	if err != badger.ErrKeyNotFound {
-		entry.Duration += existingEntry.Duration
+	if err == nil {
+		err = item.Value(func(val []byte) error {
+			existingEntry, err := b.dbUtils.Decode(val)
+			entry.Duration += existingEntry.Duration
+			return err
+		})
+		if err != nil {
+			return err
+		}
 	}
 
 	value, err := b.dbUtils.Encode(entry)
 	if err != nil {
 		return err
 	}
-
-	err = b.db.Update(func(txn *badger.Txn) error {
-		err := txn.Set(key, value)
-		return err
-	})
-
-	return err
+	return txn.Set(key, value)
 }
 
 // Read retrives entry with given id from the database
@@ -54,7 +104,10 @@ func (b BadgerDB) Read(id string) (Entry, error) {
 	var e Entry
 
 	err := b.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(id))
+		item, err := txn.Get(entryKey(id))
+		if err == badger.ErrKeyNotFound {
+			return ErrNotFound
+		}
 		if err != nil {
 			return err
 		}
@@ -74,112 +127,154 @@ func (b BadgerDB) Read(id string) (Entry, error) {
 	return e, err
 }
 
-// ReadIDList retrieves list of IDs matching the given date
-func (b BadgerDB) ReadIDList(date string) ([]string, error) {
-	var list []string
-
-	err := b.db.View(func(txn *badger.Txn) error {
-		item, err := txn.Get([]byte(date))
-		if err != nil {
-			return err
-		}
-
-		err = item.Value(func(val []byte) error {
-			entryList, err := b.dbUtils.DecodeList(val)
-			if err != nil {
-				return err
-			}
-			for _, entry := range entryList {
-				list = append(list, entry.ID)
-			}
-			return nil
-		})
-
-		return err
-	})
-	return list, err
-}
-
 // ReadList returns list of entries matching the given date
 func (b BadgerDB) ReadList(date string) ([]Entry, error) {
+	return b.ReadRange(date, date)
+}
+
+// ReadRange returns the entries of every date from the first to the last
+// given one, in order of their dates
+func (b BadgerDB) ReadRange(from, to string) ([]Entry, error) {
 	var errStr strings.Builder
 	entryList := []Entry{}
+	prefix := []byte(EntryKeyPrefix)
+	// '/' + 1 sorts after every key of the last date
+	end := []byte(EntryKeyPrefix + to + string(rune(EntryKeyDateSeparator[0]+1)))
 
-	idList, err := b.ReadIDList(date)
-	if err != nil {
-		return entryList, err
-	}
+	err := b.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Seek([]byte(EntryKeyPrefix + from + EntryKeyDateSeparator)); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if bytes.Compare(item.Key(), end) >= 0 {
+				break
+			}
 
-	for _, entryID := range idList {
-		entry, err := b.Read(entryID)
-		if err != nil {
-			fmt.Fprintf(&errStr, "%q, ", err.Error())
-			continue
+			err := item.Value(func(val []byte) error {
+				entry, err := b.dbUtils.Decode(val)
+				if err != nil {
+					return err
+				}
+				entryList = append(entryList, entry)
+				return nil
+			})
+			if err != nil {
+				fmt.Fprintf(&errStr, "%q, ", err.Error())
+			}
 		}
 
-		entryList = append(entryList, entry)
-	}
+		return nil
+	})
 
-	if len(errStr.String()) != 0 {
-		err := errors.New(fmt.Sprint(ErrReadPrefixText, errStr.String()))
-		return entryList, err
+	if err == nil && errStr.Len() != 0 {
+		err = errors.New(fmt.Sprint(ErrReadPrefixText, errStr.String()))
 	}
-
 	return entryList, err
 }
 
-// WriteList writes given entry into matching list of entries
-func (b BadgerDB) WriteList(entry Entry) error {
-	var entryList []Entry = make([]Entry, 1)
-	entryList[0] = entry
-
-	key := []byte(b.GetDate(entry))
-
-	existingEntryIDList, err := b.ReadIDList(string(key))
-	if err != nil && err != badger.ErrKeyNotFound {
+// WriteSession writes given session to database in UTC, recording the offset
+// it was recorded in. Writing a session with the same application name and
+// start time again replaces it, which allows a running session to be
+// extended.
+func (b BadgerDB) WriteSession(session Session) error {
+	session = session.UTC()
+	value, err := b.dbUtils.EncodeSession(session)
+	if err != nil {
 		return err
 	}
-	var existingEntryList []Entry
-	for _, entryID := range existingEntryIDList {
-		existingEntryList = append(existingEntryList, Entry{ID: entryID})
-	}
 
-	if err != badger.ErrKeyNotFound {
-		shouldAdd := true
-		for i := range existingEntryList {
-			if existingEntryList[i].ID == entry.ID {
-				shouldAdd = false
+	err = b.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(b.GetSessionKey(session), value)
+	})
+
+	return err
+}
+
+// ReadSessions returns the sessions that started within [from, to), ordered
+// by their start time. Their start and end are in UTC.
+func (b BadgerDB) ReadSessions(from, to time.Time) ([]Session, error) {
+	sessionList := []Session{}
+	prefix := []byte(SessionKeyPrefix)
+	end := sessionTimeKey(to)
+
+	err := b.db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Seek(sessionTimeKey(from)); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			if bytes.Compare(item.Key(), end) >= 0 {
 				break
 			}
-		}
 
-		if !shouldAdd {
-			return nil
+			err := item.Value(func(val []byte) error {
+				session, err := b.dbUtils.DecodeSession(val)
+				if err != nil {
+					return err
+				}
+				sessionList = append(sessionList, session)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
 		}
 
-		entryList = append(entryList, existingEntryList...)
-	}
-
-	value, err := b.dbUtils.EncodeList(entryList)
-	if err != nil {
-		return err
-	}
-	err = b.db.Update(func(txn *badger.Txn) error {
-		err := txn.Set(key, value)
-		return err
+		return nil
 	})
 
-	return err
+	return sessionList, err
 }
 
-// Close closes connection to database
+// SchemaVersion returns the schema version recorded in the database
+func (b BadgerDB) SchemaVersion() (int, error) {
+	version, _, err := readSchemaVersion(b.db)
+	return version, err
+}
+
+// Close closes connection to database and removes the snapshot the
+// database was opened from, if any
 func (b BadgerDB) Close() error {
-	return b.db.Close()
+	err := b.db.Close()
+	if b.snapshotDir != "" {
+		if removeErr := os.RemoveAll(b.snapshotDir); err == nil {
+			err = removeErr
+		}
+	}
+	return err
 }
 
 // GetKey returns key of the entry
 func (b BadgerDB) GetKey(entry Entry) string {
-	return entry.ID
+	return string(entryKey(entry.ID))
+}
+
+// entryKey returns the key of the entry with the given ID, made up of the
+// date and the application name of the ID
+func entryKey(id string) []byte {
+	splitID := strings.SplitN(id, EntryIDDateSeparator, 2)
+	if len(splitID) < 2 {
+		splitID = append(splitID, "")
+	}
+	return []byte(EntryKeyPrefix + splitID[0] + EntryKeyDateSeparator + splitID[1])
+}
+
+// GetSessionKey returns key of the session
+func (b BadgerDB) GetSessionKey(session Session) []byte {
+	return append(sessionTimeKey(session.Start), session.AppName...)
+}
+
+// sessionTimeKey returns the session key prefix for the given start time
+func sessionTimeKey(t time.Time) []byte {
+	key := make([]byte, len(SessionKeyPrefix)+8)
+	copy(key, SessionKeyPrefix)
+	binary.BigEndian.PutUint64(key[len(SessionKeyPrefix):], uint64(t.UnixNano())^1<<63)
+	return key
 }
 
 // GetDate extracts date from the given entry
@@ -189,7 +284,8 @@ func (b BadgerDB) GetDate(entry Entry) string {
 	return date
 }
 
-// GetBadgerDB returns a reference to BadgerDB struct
+// GetBadgerDB returns a reference to BadgerDB struct. A database with an
+// older schema version is backed up and migrated to SchemaVersion.
 func GetBadgerDB(location string, dbUtils BadgerDBUtils) (*BadgerDB, error) {
 	var utils BadgerDBUtils = BadgerDBUtilsDefault{}
 	if dbUtils != nil {
@@ -198,6 +294,11 @@ func GetBadgerDB(location string, dbUtils BadgerDBUtils) (*BadgerDB, error) {
 	options := badger.DefaultOptions(location)
 	options.Logger = nil
 	db, err := badger.Open(options)
+	if err == nil {
+		if _, err = migrate(db, location, false); err != nil {
+			db.Close()
+		}
+	}
 	badgerDB := &BadgerDB{
 		db:      db,
 		dbUtils: utils,
@@ -205,12 +306,121 @@ func GetBadgerDB(location string, dbUtils BadgerDBUtils) (*BadgerDB, error) {
 	return badgerDB, err
 }
 
+// GetBadgerDBReadOnly returns a reference to a BadgerDB struct which can only
+// be read from. The database is opened in Badger's read-only mode, which
+// allows several readers at once. While a tracker holds the database open
+// for writing, or while the database still has to be migrated to
+// SchemaVersion, a snapshot of the database directory is opened instead.
+func GetBadgerDBReadOnly(location string, dbUtils BadgerDBUtils) (*BadgerDB, error) {
+	var utils BadgerDBUtils = BadgerDBUtilsDefault{}
+	if dbUtils != nil {
+		utils = dbUtils
+	}
+
+	if _, err := os.Stat(location); err != nil {
+		return nil, err
+	}
+
+	options := badger.DefaultOptions(location)
+	options.Logger = nil
+	options.ReadOnly = true
+	db, err := badger.Open(options)
+	if err == nil {
+		var version int
+		var empty bool
+		if version, empty, err = readSchemaVersion(db); err == nil && version > SchemaVersion {
+			db.Close()
+			return nil, errSchemaTooNew(version, SchemaVersion)
+		}
+		if err == nil && (empty || version == SchemaVersion) {
+			return &BadgerDB{db: db, dbUtils: utils}, nil
+		}
+		// older schema versions are migrated in a snapshot, as the database
+		// cannot be written to
+		db.Close()
+	}
+
+	snapshotDir, snapshotErr := snapshot(location)
+	if snapshotErr == nil {
+		options = badger.DefaultOptions(snapshotDir)
+		options.Logger = nil
+		options.Truncate = true
+		db, snapshotErr = badger.Open(options)
+		if snapshotErr == nil {
+			if _, snapshotErr = migrate(db, "", false); snapshotErr == nil {
+				return &BadgerDB{db: db, dbUtils: utils, snapshotDir: snapshotDir}, nil
+			}
+			db.Close()
+		}
+		os.RemoveAll(snapshotDir)
+	}
+
+	return nil, fmt.Errorf("%s %v; %v", ErrReadOnlyPrefixText, err, snapshotErr)
+}
+
+// snapshot copies the files of the database at location, except its lock
+// file, into a new temporary directory and returns the directory. Files
+// removed by a compaction while copying cause the copy to be retried.
+func snapshot(location string) (string, error) {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		var dir string
+		dir, err = ioutil.TempDir("", "hourglass-snapshot-")
+		if err != nil {
+			return "", err
+		}
+
+		if err = copyDir(location, dir); err == nil {
+			return dir, nil
+		}
+		os.RemoveAll(dir)
+	}
+	return "", err
+}
+
+// copyDir copies the regular files of src into dst
+func copyDir(src, dst string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		if !file.Mode().IsRegular() || file.Name() == "LOCK" {
+			continue
+		}
+		if err = copyFile(filepath.Join(src, file.Name()), filepath.Join(dst, file.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
 // BadgerDBUtils represents functions required for calling DB functions
 type BadgerDBUtils interface {
 	Encode(Entry) ([]byte, error)
 	Decode([]byte) (Entry, error)
-	EncodeList([]Entry) ([]byte, error)
-	DecodeList([]byte) ([]Entry, error)
+	EncodeSession(Session) ([]byte, error)
+	DecodeSession([]byte) (Session, error)
 }
 
 // BadgerDBUtilsDefault represents default implementation of BadgerDBUtils
@@ -232,27 +442,18 @@ func (b BadgerDBUtilsDefault) Decode(value []byte) (Entry, error) {
 	return entry, err
 }
 
-// EncodeList returns encoded value of the given entry list
-func (b BadgerDBUtilsDefault) EncodeList(entryList []Entry) ([]byte, error) {
+// EncodeSession returns encoded value of the given session
+func (b BadgerDBUtilsDefault) EncodeSession(session Session) ([]byte, error) {
 	var buff bytes.Buffer
-	idList := []string{}
-
-	for _, entry := range entryList {
-		idList = append(idList, entry.ID)
-	}
 	e := gob.NewEncoder(&buff)
-	err := e.Encode(idList)
+	err := e.Encode(session)
 	return buff.Bytes(), err
 }
 
-// DecodeList returns entry after decoding the given value
-func (b BadgerDBUtilsDefault) DecodeList(value []byte) ([]Entry, error) {
-	var idList []string
-	var entryList []Entry
+// DecodeSession returns session after decoding the given value
+func (b BadgerDBUtilsDefault) DecodeSession(value []byte) (Session, error) {
+	var session Session
 	d := gob.NewDecoder(bytes.NewReader(value))
-	err := d.Decode(&idList)
-	for _, id := range idList {
-		entryList = append(entryList, Entry{ID: id})
-	}
-	return entryList, err
+	err := d.Decode(&session)
+	return session, err
 }
@@ -2,14 +2,16 @@ package data_test
 
 import (
 	"github.com/shldhll/hourglass/data"
-	"github.com/shldhll/hourglass/tracker"
 
-	"testing"
+	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
 	"reflect"
+	"strings"
+	"sync"
+	"testing"
 	"time"
-	"errors"
-	"fmt"
 
 	"github.com/dgraph-io/badger"
 )
@@ -24,19 +26,13 @@ const (
 var stubTime = time.Date(1970, 01, 01, 0, 0, 0, 0, time.UTC)
 
 type stubDBUtils struct {
-	errDelayCount      int
-	encodeErrCount     int
-	decodeErrCount     int
-	encodeListErrCount int
-	decodeListErrCount int
-	encodeErr          error
-	decodeErr          error
-	encodeListErr      error
-	decodeListErr      error
-	showEncodeErr      bool
-	showDecodeErr      bool
-	showEncodeListErr  bool
-	showDecodeListErr  bool
+	errDelayCount  int
+	encodeErrCount int
+	decodeErrCount int
+	encodeErr      error
+	decodeErr      error
+	showEncodeErr  bool
+	showDecodeErr  bool
 }
 
 func (s *stubDBUtils) Encode(data.Entry) ([]byte, error) {
@@ -55,20 +51,12 @@ func (s *stubDBUtils) Decode([]byte) (data.Entry, error) {
 	return data.Entry{}, nil
 }
 
-func (s *stubDBUtils) EncodeList([]data.Entry) ([]byte, error) {
-	if s.showEncodeListErr && s.encodeListErrCount >= s.errDelayCount {
-		return nil, s.encodeListErr
-	}
-	s.encodeListErrCount++
-	return nil, nil
+func (s *stubDBUtils) EncodeSession(session data.Session) ([]byte, error) {
+	return data.BadgerDBUtilsDefault{}.EncodeSession(session)
 }
 
-func (s *stubDBUtils) DecodeList([]byte) ([]data.Entry, error) {
-	if s.showDecodeListErr && s.decodeListErrCount >= s.errDelayCount {
-		return nil, errors.New("DecodeList error")
-	}
-	s.decodeListErrCount++
-	return nil, nil
+func (s *stubDBUtils) DecodeSession(value []byte) (data.Session, error) {
+	return data.BadgerDBUtilsDefault{}.DecodeSession(value)
 }
 
 func TestGetBadgerDB(t *testing.T) {
@@ -87,18 +75,18 @@ func TestBadgerDBClose(t *testing.T) {
 	assertError(t, err)
 }
 
-func TestBadgerDBWrite(t *testing.T) {
-	t.Run("Simple write test", func(t *testing.T) {
+func TestBadgerDBAddEntry(t *testing.T) {
+	t.Run("Entry added", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
 
 		entry := createEntry()
-		err = db.Write(entry)
+		err = db.AddEntry(entry)
 		assertErrorFatal(t, err)
 
-		got, err := db.Read(db.GetKey(entry))
+		got, err := db.Read(entry.ID)
 		assertErrorFatal(t, err)
 
 		want := entry
@@ -107,7 +95,7 @@ func TestBadgerDBWrite(t *testing.T) {
 		}
 	})
 
-	t.Run("Multi write test", func(t *testing.T) {
+	t.Run("Durations summed up", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
@@ -116,11 +104,11 @@ func TestBadgerDBWrite(t *testing.T) {
 		entry := createEntry()
 
 		for i := 0; i < multiWriteCount; i++ {
-			err = db.Write(entry)
+			err = db.AddEntry(entry)
 			assertErrorFatal(t, err)
 		}
 
-		got, err := db.Read(db.GetKey(entry))
+		got, err := db.Read(entry.ID)
 		assertErrorFatal(t, err)
 
 		entry.Duration = stubDuration * multiWriteCount
@@ -130,7 +118,7 @@ func TestBadgerDBWrite(t *testing.T) {
 		}
 	})
 
-	t.Run("Empty key write test", func(t *testing.T) {
+	t.Run("Empty ID", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
@@ -138,13 +126,13 @@ func TestBadgerDBWrite(t *testing.T) {
 
 		entry := data.Entry{}
 
-		err = db.Write(entry)
+		err = db.AddEntry(entry)
 		if err != badger.ErrEmptyKey {
 			assertError(t, err)
 		}
 	})
 
-	t.Run("Encode error write test", func(t *testing.T) {
+	t.Run("Encode error", func(t *testing.T) {
 		defer clean()
 		encodeErr := errors.New("Encode error")
 		dbUtils := stubDBUtils{showEncodeErr: true, encodeErr: encodeErr}
@@ -152,114 +140,74 @@ func TestBadgerDBWrite(t *testing.T) {
 		assertErrorFatal(t, err)
 		defer db.Close()
 
-		err = db.Write(data.Entry{ID: "id"})
+		err = db.AddEntry(data.Entry{ID: "id"})
 		assertErrorEqual(t, err, encodeErr)
 	})
-}
 
-func TestBadgerDBWriteList(t *testing.T) {
-	t.Run("WriteList test", func(t *testing.T) {
+	t.Run("Entry listed for its day", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
 
-		num := 5
-		entryList := createEntryList(num)
-		want := make([]string, num)
-
-		for i, entry := range entryList {
-			err = db.WriteList(entry)
-			assertErrorFatal(t, err)
-			want[i] = entry.ID
-		}
-
-		for i, entry := range entryList {
-			err = db.WriteList(entry)
+		entry := createEntry()
+		for i := 0; i < multiWriteCount; i++ {
+			err = db.AddEntry(entry)
 			assertErrorFatal(t, err)
-			want[i] = entry.ID
 		}
 
-		got, err := db.ReadIDList(db.GetDate(entryList[num-1]))
+		got, err := db.ReadList(db.GetDate(entry))
 		assertErrorFatal(t, err)
 
-		for _, entry1 := range got {
-			ok := false
-			for _, entry2 := range want {
-				if entry1 == entry2 {
-					ok = true
-					break
-				}
-			}
-			if !ok {
-				t.Errorf("Entry ID %v of got not found in want", entry1)
-			}
+		entry.Duration = stubDuration * multiWriteCount
+		want := []data.Entry{entry}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
 		}
 	})
 
-	t.Run("Adding/overwriting to existing list", func(t *testing.T) {
+	t.Run("Concurrent writers", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
 
-		num := 5
-		entryList := createEntryList(num)
-		want := make([]string, num)
-
-		err = db.WriteList(entryList[num-1])
-		assertErrorFatal(t, err)
-
-		for i, entry := range entryList {
-			err = db.WriteList(entry)
+		writers, writes := 4, 25
+		entryList := createEntryList(writers)
+		var wg sync.WaitGroup
+		errs := make(chan error, writers*writes*2)
+		for _, entry := range entryList {
+			wg.Add(1)
+			go func(entry data.Entry) {
+				defer wg.Done()
+				for i := 0; i < writes; i++ {
+					// every writer adds to its own entry and to a shared one
+					errs <- db.AddEntry(entry)
+					errs <- db.AddEntry(entryList[0])
+				}
+			}(entry)
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
 			assertErrorFatal(t, err)
-			want[i] = entry.ID
 		}
 
-		got, err := db.ReadIDList(db.GetDate(entryList[num-1]))
+		got, err := db.ReadList(db.GetDate(entryList[0]))
 		assertErrorFatal(t, err)
-
-		for _, entry1 := range got {
-			ok := false
-			for _, entry2 := range want {
-				if entry1 == entry2 {
-					ok = true
-					break
-				}
+		if len(got) != writers {
+			t.Fatalf("got %d entries listed, want %d", len(got), writers)
+		}
+		for _, entry := range got {
+			want := time.Duration(writes) * stubDuration
+			if entry.ID == entryList[0].ID {
+				want += time.Duration(writers*writes) * stubDuration
 			}
-			if !ok {
-				t.Errorf("Entry ID %v of got not found in want", entry1)
+			if entry.Duration != want {
+				t.Errorf("got %v for %q, want %v", entry.Duration, entry.ID, want)
 			}
 		}
 	})
-
-	t.Run("Empty key", func(t *testing.T) {
-		defer clean()
-		db, err := data.GetBadgerDB(dbLocation, nil)
-		assertErrorFatal(t, err)
-		defer db.Close()
-
-		entry := data.Entry{}
-
-		err = db.WriteList(entry)
-		if err != badger.ErrEmptyKey {
-			assertError(t, err)
-		}
-	})
-
-	t.Run("EncodeList error", func(t *testing.T) {
-		defer clean()
-		encodeListErr := errors.New("EncodeList error")
-		dbUtils := stubDBUtils{showEncodeListErr: true, encodeListErr: encodeListErr}
-		db, err := data.GetBadgerDB(dbLocation, &dbUtils)
-		assertErrorFatal(t, err)
-		defer db.Close()
-
-		entry := createEntry()
-
-		err = db.WriteList(entry)
-		assertErrorEqual(t, err, encodeListErr)
-	})
 }
 
 func TestBadgerDBRead(t *testing.T) {
@@ -270,7 +218,7 @@ func TestBadgerDBRead(t *testing.T) {
 		defer db.Close()
 
 		entry := createEntry()
-		err = db.Write(entry)
+		err = db.AddEntry(entry)
 		assertErrorFatal(t, err)
 
 		want := entry
@@ -291,7 +239,7 @@ func TestBadgerDBRead(t *testing.T) {
 		defer db.Close()
 
 		entry := createEntry()
-		err = db.Write(entry)
+		err = db.AddEntry(entry)
 		assertErrorFatal(t, err)
 
 		_, err = db.Read(entry.ID)
@@ -299,138 +247,297 @@ func TestBadgerDBRead(t *testing.T) {
 	})
 }
 
-func TestBadgerDBReadIDList(t *testing.T) {
-	t.Run("ReadIDList test", func(t *testing.T) {
+func TestBadgerDBReadList(t *testing.T) {
+	t.Run("ReadList test", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
+
 		num := 5
 		entryList := createEntryList(num)
-		date := db.GetDate(entryList[0])
 
 		for _, entry := range entryList {
-			err := db.Write(entry)
-			assertErrorFatal(t, err)
-			err = db.WriteList(entry)
+			err := db.AddEntry(entry)
 			assertErrorFatal(t, err)
 		}
 
-		readEntryList := make([]data.Entry, num)
-		idList, err := db.ReadIDList(date)
+		date := db.GetDate(entryList[0])
+		readList, err := db.ReadList(date)
 		assertErrorFatal(t, err)
 
-		for i, id := range idList {
-			entry, err := db.Read(id)
-			assertError(t, err)
-			readEntryList[i] = entry
+		if !reflect.DeepEqual(readList, entryList) {
+			t.Errorf("got %v, want %v", readList, entryList)
 		}
+	})
 
-		for _, entry1 := range entryList {
-			ok := false
-			for _, entry2 := range readEntryList {
-				if entry1.ID == entry2.ID {
-					ok = true
-					break
-				}
-			}
-			if !ok {
-				t.Errorf("Entry %v of entryList not found in readEntryList", entry1)
-			}
+	t.Run("Empty day", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+		entry := createEntry()
+
+		got, err := db.ReadList(db.GetDate(entry))
+		assertErrorFatal(t, err)
+		if len(got) != 0 {
+			t.Errorf("got %v, want no entries", got)
 		}
 	})
 
-	t.Run("DecodeList error", func(t *testing.T) {
+	t.Run("Decode error", func(t *testing.T) {
 		defer clean()
 		num := 5
-		decodeListErr := errors.New("DecodeList error")
-		dbUtils := stubDBUtils{
-			showDecodeListErr: true,
-			decodeListErr:     decodeListErr,
-			errDelayCount:     num - 1}
+		decodeErr := errors.New("Decode error")
+		dbUtils := stubDBUtils{showDecodeErr: true, decodeErr: decodeErr, errDelayCount: num - 1}
 		db, err := data.GetBadgerDB(dbLocation, &dbUtils)
 		assertErrorFatal(t, err)
 		defer db.Close()
-		entryList := createEntryList(num)
-		date := db.GetDate(entryList[0])
 
+		entryList := createEntryList(num)
 		for _, entry := range entryList {
-			err := db.Write(entry)
+			err = db.AddEntry(entry)
 			assertErrorFatal(t, err)
-			err = db.WriteList(entry)
+		}
+
+		date := db.GetDate(entryList[0])
+		got, err := db.ReadList(date)
+		if err == nil || !strings.HasPrefix(err.Error(), data.ErrReadPrefixText) {
+			t.Errorf("got %v, want %q error", err, data.ErrReadPrefixText)
+		}
+		if len(got) != num-1 {
+			t.Errorf("got %d entries, want %d", len(got), num-1)
+		}
+	})
+}
+
+func TestBadgerDBReadRange(t *testing.T) {
+	defer clean()
+	db, err := data.GetBadgerDB(dbLocation, nil)
+	assertErrorFatal(t, err)
+	defer db.Close()
+
+	days := make([][]data.Entry, 4)
+	for i := range days {
+		day := stubTime.AddDate(0, 0, i)
+		days[i] = []data.Entry{
+			{ID: data.CreateID("A", day), AppName: "A", Duration: stubDuration},
+			{ID: data.CreateID("B", day), AppName: "B", Duration: stubDuration},
+		}
+		for _, entry := range days[i] {
+			err = db.AddEntry(entry)
 			assertErrorFatal(t, err)
 		}
+	}
+
+	got, err := db.ReadRange(db.GetDate(days[1][0]), db.GetDate(days[2][0]))
+	assertErrorFatal(t, err)
+
+	want := append(append([]data.Entry{}, days[1]...), days[2]...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestBadgerDBWriteSession(t *testing.T) {
+	t.Run("Write and read session", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		session := createSession(stubName, stubTime)
+		err = db.WriteSession(session)
+		assertErrorFatal(t, err)
+
+		got, err := db.ReadSessions(stubTime, stubTime.Add(stubDuration))
+		assertErrorFatal(t, err)
+
+		want := []data.Session{session}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Extend session", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		session := createSession(stubName, stubTime)
+		err = db.WriteSession(session)
+		assertErrorFatal(t, err)
+
+		session.End = session.End.Add(stubDuration)
+		err = db.WriteSession(session)
+		assertErrorFatal(t, err)
+
+		got, err := db.ReadSessions(stubTime, stubTime.Add(stubDuration))
+		assertErrorFatal(t, err)
+
+		want := []data.Session{session}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Stored in UTC with offset", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		zone := time.FixedZone("UTC+2", 2*60*60)
+		session := createSession(stubName, stubTime.In(zone))
+		err = db.WriteSession(session)
+		assertErrorFatal(t, err)
+
+		got, err := db.ReadSessions(stubTime, stubTime.Add(stubDuration))
+		assertErrorFatal(t, err)
 
-		_, err = db.ReadIDList(date)
-		assertErrorEqual(t, err, decodeListErr)
+		want := []data.Session{createSession(stubName, stubTime)}
+		want[0].Offset = 2 * 60 * 60
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got := got[0].In(got[0].Zone()).Start.Hour(); got != 2 {
+			t.Errorf("got %v, want %v", got, 2)
+		}
 	})
 }
 
-func TestBadgerDBReadList(t *testing.T) {
-	t.Run("ReadList test", func(t *testing.T) {
+func TestBadgerDBReadSessions(t *testing.T) {
+	t.Run("Sessions within range in order", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
 
 		num := 5
-		entryList := createEntryList(num)
-
-		for _, entry := range entryList {
-			err := db.Write(entry)
-			assertErrorFatal(t, err)
-			err = db.WriteList(entry)
+		sessionList := make([]data.Session, num)
+		for i := num - 1; i >= 0; i-- {
+			sessionList[i] = createSession(fmt.Sprint(i), stubTime.Add(time.Duration(i)*stubDuration))
+			err = db.WriteSession(sessionList[i])
 			assertErrorFatal(t, err)
 		}
 
-		date := db.GetDate(entryList[0])
-		readList, err := db.ReadList(date)
+		got, err := db.ReadSessions(sessionList[1].Start, sessionList[num-1].Start)
 		assertErrorFatal(t, err)
 
-		for _, entry1 := range entryList {
-			ok := false
-			for _, entry2 := range readList {
-				if entry1.ID == entry2.ID {
-					ok = true
-					break
-				}
-			}
-			if !ok {
-				t.Errorf("Entry %v of entryList not found in readEntryList", entry1)
-			}
+		want := sessionList[1 : num-1]
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
 		}
 	})
 
-	t.Run("Error from ReadIDList", func(t *testing.T) {
+	t.Run("Empty range", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
-		entry := createEntry()
 
-		date := db.GetDate(entry)
-		_, err = db.ReadList(date)
-		assertErrorEqual(t, err, badger.ErrKeyNotFound)
+		err = db.WriteSession(createSession(stubName, stubTime))
+		assertErrorFatal(t, err)
+
+		got, err := db.ReadSessions(stubTime.Add(stubDuration), stubTime.Add(2*stubDuration))
+		assertErrorFatal(t, err)
+
+		if len(got) != 0 {
+			t.Errorf("got %v, want no sessions", got)
+		}
 	})
 
-	t.Run("Error from Read", func(t *testing.T) {
+	t.Run("Entries are not sessions", func(t *testing.T) {
 		defer clean()
 		db, err := data.GetBadgerDB(dbLocation, nil)
 		assertErrorFatal(t, err)
 		defer db.Close()
 
-		num := 5
-		entryList := createEntryList(num)
+		entry := createEntry()
+		err = db.AddEntry(entry)
+		assertErrorFatal(t, err)
 
-		for _, entry := range entryList {
-			err = db.WriteList(entry)
+		got, err := db.ReadSessions(stubTime.AddDate(-1, 0, 0), stubTime.AddDate(1, 0, 0))
+		assertErrorFatal(t, err)
+
+		if len(got) != 0 {
+			t.Errorf("got %v, want no sessions", got)
+		}
+	})
+}
+
+func TestGetBadgerDBReadOnly(t *testing.T) {
+	t.Run("Several readers", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		entry := createEntry()
+		err = db.AddEntry(entry)
+		assertErrorFatal(t, err)
+		err = db.Close()
+		assertErrorFatal(t, err)
+
+		reader1, err := data.GetBadgerDBReadOnly(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer reader1.Close()
+		reader2, err := data.GetBadgerDBReadOnly(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer reader2.Close()
+
+		for _, reader := range []*data.BadgerDB{reader1, reader2} {
+			got, err := reader.Read(entry.ID)
 			assertErrorFatal(t, err)
+			if !reflect.DeepEqual(got, entry) {
+				t.Errorf("got %v, want %v", got, entry)
+			}
 		}
 
-		date := db.GetDate(entryList[0])
-		_, err = db.ReadList(date)
-		assertNotNil(t, err)
+		err = reader1.AddEntry(entry)
+		assertErrorEqual(t, err, badger.ErrReadOnlyTxn)
+	})
 
+	t.Run("Snapshot while writer is open", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		entry := createEntry()
+		err = db.AddEntry(entry)
+		assertErrorFatal(t, err)
+		session := createSession(stubName, stubTime)
+		err = db.WriteSession(session)
+		assertErrorFatal(t, err)
+
+		reader, err := data.GetBadgerDBReadOnly(dbLocation, nil)
+		assertErrorFatal(t, err)
+
+		got, err := reader.ReadList(reader.GetDate(entry))
+		assertErrorFatal(t, err)
+		if !reflect.DeepEqual(got, []data.Entry{entry}) {
+			t.Errorf("got %v, want %v", got, []data.Entry{entry})
+		}
+
+		sessions, err := reader.ReadSessions(stubTime, stubTime.Add(stubDuration))
+		assertErrorFatal(t, err)
+		if !reflect.DeepEqual(sessions, []data.Session{session}) {
+			t.Errorf("got %v, want %v", sessions, []data.Session{session})
+		}
+
+		err = reader.Close()
+		assertErrorFatal(t, err)
+
+		err = db.AddEntry(entry)
+		assertError(t, err)
+	})
+
+	t.Run("Missing database", func(t *testing.T) {
+		defer clean()
+		_, err := data.GetBadgerDBReadOnly(dbLocation, nil)
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("got %v, want %v", err, os.ErrNotExist)
+		}
 	})
 }
 
@@ -469,20 +576,35 @@ func assertEqual(t *testing.T, got, want string) {
 	}
 }
 
+// clean removes the test database along with the backups and SQLite files
+// next to it
 func clean() error {
+	files, _ := filepath.Glob(dbLocation + ".*")
+	for _, file := range files {
+		os.Remove(file)
+	}
 	return os.RemoveAll(dbLocation)
 }
 
 func createEntry() data.Entry {
-	id := tracker.CreateID(stubName, stubTime)
+	id := data.CreateID(stubName, stubTime)
 	return data.Entry{id, stubName, stubDuration}
 }
 
 func createEntryList(num int) []data.Entry {
 	entryList := make([]data.Entry, num)
 	for i := 0; i < num; i++ {
-		id := tracker.CreateID(fmt.Sprint(i), stubTime)
+		id := data.CreateID(fmt.Sprint(i), stubTime)
 		entryList[i] = data.Entry{id, stubName, stubDuration}
 	}
 	return entryList
 }
+
+func createSession(appName string, start time.Time) data.Session {
+	return data.Session{
+		AppName: appName,
+		Title:   "Title of " + appName,
+		Start:   start,
+		End:     start.Add(stubDuration),
+	}
+}
@@ -0,0 +1,334 @@
+// Package datatest checks that implementations of data.DB behave alike.
+package datatest
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+)
+
+const (
+	// ConcurrentWriters is the number of goroutines writing at once in the
+	// concurrent write tests
+	ConcurrentWriters = 4
+	// WritesPerWriter is the number of writes of each concurrent writer
+	WritesPerWriter = 25
+	// VolumeDays and VolumeApps are the number of days and of applications
+	// per day written in the large volume tests. Short tests write a tenth
+	// of the applications.
+	VolumeDays = 30
+	VolumeApps = 100
+)
+
+// day is the first day the entries and sessions written by the tests are on
+var day = time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// TestDB runs the behavioral tests of data.DB against databases returned by
+// newDB. Every test asks for a new, empty database. newDB should fail t if
+// the database cannot be created and close the database using t.Cleanup.
+func TestDB(t *testing.T, newDB func(t *testing.T) data.DB) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, db data.DB)
+	}{
+		{"Entries added up", testAccumulation},
+		{"Entries listed by day", testDayListing},
+		{"Missing keys", testMissing},
+		{"Unicode application names", testUnicode},
+		{"Sessions stored in UTC with offset", testSessionOffset},
+		{"Sessions replaced and ordered", testSessionOrder},
+		{"Concurrent writes", testConcurrentWrites},
+		{"Large volumes", testLargeVolume},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newDB(t))
+		})
+	}
+}
+
+// entry returns an entry of the application on the given day
+func entry(appName string, day time.Time, duration time.Duration) data.Entry {
+	return data.Entry{ID: data.CreateID(appName, day), AppName: appName, Duration: duration}
+}
+
+// session returns a session of the application starting at start
+func session(appName string, start time.Time) data.Session {
+	return data.Session{
+		AppName: appName,
+		Title:   "Title of " + appName,
+		Start:   start,
+		End:     start.Add(time.Minute),
+	}
+}
+
+// date returns the date of the day n days after the first day
+func date(n int) string {
+	return day.AddDate(0, 0, n).Format(data.EntryIDDateFormat)
+}
+
+func testAccumulation(t *testing.T, db data.DB) {
+	e := entry("App Name", day, time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := db.AddEntry(e); err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+	}
+
+	got, err := db.Read(e.ID)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	want := e
+	want.Duration = 3 * time.Hour
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testDayListing(t *testing.T, db data.DB) {
+	days := make([][]data.Entry, 3)
+	for i := range days {
+		d := day.AddDate(0, 0, i)
+		days[i] = []data.Entry{entry("A", d, time.Hour), entry("B", d, time.Hour)}
+	}
+	for i := len(days) - 1; i >= 0; i-- {
+		for j := len(days[i]) - 1; j >= 0; j-- {
+			if err := db.AddEntry(days[i][j]); err != nil {
+				t.Fatalf("No error expected, got %v", err)
+			}
+		}
+	}
+
+	got, err := db.ReadList(date(1))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if !reflect.DeepEqual(got, days[1]) {
+		t.Errorf("got %v, want %v", got, days[1])
+	}
+
+	got, err = db.ReadRange(date(1), date(2))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	want := append(append([]data.Entry{}, days[1]...), days[2]...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testMissing(t *testing.T, db data.DB) {
+	if err := db.AddEntry(entry("A", day, time.Hour)); err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if err := db.WriteSession(session("A", day)); err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	_, err := db.Read(data.CreateID("B", day))
+	if !errors.Is(err, data.ErrNotFound) {
+		t.Errorf("got %v, want %v", err, data.ErrNotFound)
+	}
+
+	entries, err := db.ReadList(date(1))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if entries == nil || len(entries) != 0 {
+		t.Errorf("got %#v, want no entries", entries)
+	}
+
+	sessions, err := db.ReadSessions(day.AddDate(0, 0, 1), day.AddDate(0, 0, 2))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if sessions == nil || len(sessions) != 0 {
+		t.Errorf("got %#v, want no sessions", sessions)
+	}
+}
+
+func testUnicode(t *testing.T, db data.DB) {
+	names := []string{"Éditeur de texte", "日本語 エディタ", "Терминал", "🎵 Music", "naïve/app_name"}
+	for _, name := range names {
+		if err := db.AddEntry(entry(name, day, time.Hour)); err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if err := db.WriteSession(session(name, day)); err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+	}
+
+	for _, name := range names {
+		want := entry(name, day, time.Hour)
+		got, err := db.Read(want.ID)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	}
+
+	entries, err := db.ReadList(date(0))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if len(entries) != len(names) {
+		t.Errorf("got %v entries, want %v", len(entries), len(names))
+	}
+
+	sessions, err := db.ReadSessions(day, day.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	got := make(map[string]bool)
+	for _, s := range sessions {
+		got[s.AppName] = true
+	}
+	for _, name := range names {
+		if !got[name] {
+			t.Errorf("got %v, want a session of %q", sessions, name)
+		}
+	}
+}
+
+func testSessionOffset(t *testing.T, db data.DB) {
+	s := session("App Name", day.In(time.FixedZone("UTC+2", 2*60*60)))
+	s.Class = "app"
+	s.PID = 42
+	s.Exe = "/usr/bin/app"
+	s.Cmdline = []string{"app", "--flag"}
+	if err := db.WriteSession(s); err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	got, err := db.ReadSessions(day, day.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	want := []data.Session{s.UTC()}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testSessionOrder(t *testing.T, db data.DB) {
+	later := session("B", day.Add(time.Minute))
+	first := session("B", day)
+	other := session("A", day)
+	outside := session("A", day.Add(time.Hour))
+	for _, s := range []data.Session{later, first, other, outside} {
+		if err := db.WriteSession(s); err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+	}
+	first.End = first.End.Add(time.Minute)
+	if err := db.WriteSession(first); err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	got, err := db.ReadSessions(day, day.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	want := []data.Session{other, first, later}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testConcurrentWrites(t *testing.T, db data.DB) {
+	e := entry("App Name", day, time.Second)
+	errs := make(chan error, ConcurrentWriters*WritesPerWriter*2)
+	var wg sync.WaitGroup
+	for w := 0; w < ConcurrentWriters; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < WritesPerWriter; i++ {
+				errs <- db.AddEntry(e)
+				errs <- db.WriteSession(session(fmt.Sprint("writer ", w), day.Add(time.Duration(i)*time.Minute)))
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+	}
+
+	got, err := db.Read(e.ID)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if want := ConcurrentWriters * WritesPerWriter * time.Second; got.Duration != want {
+		t.Errorf("got %v, want %v", got.Duration, want)
+	}
+
+	sessions, err := db.ReadSessions(day, day.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if want := ConcurrentWriters * WritesPerWriter; len(sessions) != want {
+		t.Errorf("got %v sessions, want %v", len(sessions), want)
+	}
+}
+
+func testLargeVolume(t *testing.T, db data.DB) {
+	apps := VolumeApps
+	if testing.Short() {
+		apps /= 10
+	}
+
+	var want []data.Entry
+	for i := 0; i < VolumeDays; i++ {
+		for j := 0; j < apps; j++ {
+			e := entry(fmt.Sprintf("app%03d", j), day.AddDate(0, 0, i), time.Minute)
+			if err := db.AddEntry(e); err != nil {
+				t.Fatalf("No error expected, got %v", err)
+			}
+			if err := db.WriteSession(session(e.AppName, day.AddDate(0, 0, i).Add(time.Duration(j)*time.Minute))); err != nil {
+				t.Fatalf("No error expected, got %v", err)
+			}
+			want = append(want, e)
+		}
+	}
+
+	got, err := db.ReadRange(date(0), date(VolumeDays-1))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v entries, want %v in order of their IDs", len(got), len(want))
+	}
+
+	got, err = db.ReadList(date(VolumeDays / 2))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if !reflect.DeepEqual(got, want[VolumeDays/2*apps:(VolumeDays/2+1)*apps]) {
+		t.Errorf("got %v entries, want the %v of the day", len(got), apps)
+	}
+
+	sessions, err := db.ReadSessions(day, day.AddDate(0, 0, VolumeDays))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if len(sessions) != len(want) {
+		t.Errorf("got %v sessions, want %v", len(sessions), len(want))
+	}
+	for i := 1; i < len(sessions); i++ {
+		if sessions[i].Start.Before(sessions[i-1].Start) {
+			t.Fatalf("got session starting at %v after one starting at %v", sessions[i].Start, sessions[i-1].Start)
+		}
+	}
+}
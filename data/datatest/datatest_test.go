@@ -0,0 +1,14 @@
+package datatest_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/data/datatest"
+
+	"testing"
+)
+
+func TestMemoryDB(t *testing.T) {
+	datatest.TestDB(t, func(t *testing.T) data.DB {
+		return data.NewMemoryDB()
+	})
+}
@@ -0,0 +1,22 @@
+package data
+
+import (
+	"time"
+)
+
+// Day returns the midnight of the day t is credited to when days start
+// dayStart after midnight
+func Day(t time.Time, dayStart time.Duration) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if t.Before(StartOfDay(day, dayStart)) {
+		day = day.AddDate(0, 0, -1)
+	}
+	return day
+}
+
+// StartOfDay returns the time the given day starts at when days start
+// dayStart after midnight. dayStart is wall clock time, so a day starting at
+// 04:00 starts at 04:00 on days the clocks change as well.
+func StartOfDay(day time.Time, dayStart time.Duration) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, int(dayStart), day.Location())
+}
@@ -0,0 +1,37 @@
+package data_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+
+	"testing"
+	"time"
+)
+
+func TestDay(t *testing.T) {
+	tests := []struct {
+		time     time.Time
+		dayStart time.Duration
+		want     time.Time
+	}{
+		{stubTime, 0, stubTime},
+		{stubTime.Add(-time.Nanosecond), 0, stubTime.AddDate(0, 0, -1)},
+		{stubTime.Add(3 * time.Hour), 4 * time.Hour, stubTime.AddDate(0, 0, -1)},
+		{stubTime.Add(4 * time.Hour), 4 * time.Hour, stubTime},
+		{stubTime.Add(23 * time.Hour), 4 * time.Hour, stubTime},
+	}
+
+	for _, test := range tests {
+		if got := data.Day(test.time, test.dayStart); !got.Equal(test.want) {
+			t.Errorf("got %v, want %v", got, test.want)
+		}
+	}
+}
+
+func TestStartOfDay(t *testing.T) {
+	got := data.StartOfDay(stubTime, 4*time.Hour+30*time.Minute)
+	want := time.Date(1970, 01, 01, 4, 30, 0, 0, time.UTC)
+
+	if !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -1,15 +1,42 @@
 package data
 
 import (
+	"errors"
 	"time"
 )
 
+const (
+	// IdleAppName is the application name recorded while the user is idle
+	IdleAppName = "idle"
+	// PausedAppName is the application name recorded while tracking is paused
+	PausedAppName = "paused"
+	// SleepAppName is the application name recorded while the system sleeps
+	SleepAppName = "sleep"
+	// LockedAppName is the application name recorded while the screen is locked
+	LockedAppName = "locked"
+)
+
+// ErrNotFound is returned when no entry is stored under an ID
+var ErrNotFound = errors.New("entry not found")
+
+// Active reports whether the time recorded for appName was spent in an
+// application, rather than idle, paused, asleep or locked
+func Active(appName string) bool {
+	switch appName {
+	case IdleAppName, PausedAppName, SleepAppName, LockedAppName:
+		return false
+	}
+	return true
+}
+
 // DB represents a database
 type DB interface {
-	Write(entry Entry) error
-	WriteList(entry Entry) error
+	AddEntry(entry Entry) error
 	Read(id string) (Entry, error)
 	ReadList(date string) ([]Entry, error)
+	ReadRange(from, to string) ([]Entry, error)
+	WriteSession(session Session) error
+	ReadSessions(from, to time.Time) ([]Session, error)
 }
 
 // Entry represents a database entry
@@ -18,3 +45,47 @@ type Entry struct {
 	AppName  string
 	Duration time.Duration
 }
+
+// Session represents an uninterrupted span during which a window was focused.
+// Class, PID, Exe and Cmdline describe the window class and the process
+// owning the window, if known. Offset is the offset of the zone the session
+// was recorded in, in seconds east of UTC.
+type Session struct {
+	AppName string
+	Title   string
+	Class   string
+	PID     int
+	Exe     string
+	Cmdline []string
+	Start   time.Time
+	End     time.Time
+	Offset  int
+}
+
+// Duration returns the length of the session
+func (s Session) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+// UTC returns the session with its start and end in UTC. Unless they are
+// already in UTC, the offset of the zone of the start is recorded first.
+func (s Session) UTC() Session {
+	if s.Start.Location() != time.UTC {
+		_, s.Offset = s.Start.Zone()
+	}
+	s.Start = s.Start.UTC()
+	s.End = s.End.UTC()
+	return s
+}
+
+// In returns the session with its start and end in the given location
+func (s Session) In(loc *time.Location) Session {
+	s.Start = s.Start.In(loc)
+	s.End = s.End.In(loc)
+	return s
+}
+
+// Zone returns a fixed zone with the offset the session was recorded in
+func (s Session) Zone() *time.Location {
+	return time.FixedZone("", s.Offset)
+}
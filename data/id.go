@@ -0,0 +1,26 @@
+package data
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// EntryIDStringFormat is the format used for Sprintf function to create the ID for database entry
+	EntryIDStringFormat = "%v_%s"
+	// EntryIDDateFormat is the date format used in the ID
+	EntryIDDateFormat = "2006-01-02"
+	// EntryIDNameReplaceOld is the string which is to be replaced by EntryIDNameReplaceNew
+	EntryIDNameReplaceOld = " "
+	// EntryIDNameReplaceNew is the string which replaces EntryIDNameReplaceOld
+	EntryIDNameReplaceNew = ""
+)
+
+// CreateID creates an ID string using formatted date and string
+func CreateID(appName string, date time.Time) string {
+	formattedDate := date.Format(EntryIDDateFormat)
+	formattedAppName := strings.ReplaceAll(appName, EntryIDNameReplaceOld, EntryIDNameReplaceNew)
+
+	return fmt.Sprintf(EntryIDStringFormat, formattedDate, formattedAppName)
+}
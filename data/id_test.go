@@ -0,0 +1,18 @@
+package data_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCreateID(t *testing.T) {
+	got := data.CreateID(stubName, stubTime)
+	want := fmt.Sprintf(data.EntryIDStringFormat, stubTime.Format(data.EntryIDDateFormat), strings.ReplaceAll(stubName, data.EntryIDNameReplaceOld, data.EntryIDNameReplaceNew))
+
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
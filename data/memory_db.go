@@ -0,0 +1,126 @@
+package data
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryDB represents a database held in memory, which is lost once the
+// process exits. It is meant for tests.
+type MemoryDB struct {
+	mu       sync.Mutex
+	entries  map[string]Entry
+	sessions map[memorySessionKey]Session
+}
+
+// memorySessionKey identifies a session like the key of a Badger session
+type memorySessionKey struct {
+	start   int64
+	appName string
+}
+
+// NewMemoryDB returns an empty MemoryDB
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{
+		entries:  make(map[string]Entry),
+		sessions: make(map[memorySessionKey]Session),
+	}
+}
+
+// AddEntry adds the duration of the given entry to the entry stored under
+// the same ID
+func (m *MemoryDB) AddEntry(entry Entry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry.Duration += m.entries[entry.ID].Duration
+	m.entries[entry.ID] = entry
+	return nil
+}
+
+// Read retrives entry with given id from the database
+func (m *MemoryDB) Read(id string) (Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[id]
+	if !ok {
+		return Entry{}, ErrNotFound
+	}
+	return entry, nil
+}
+
+// ReadList returns list of entries matching the given date
+func (m *MemoryDB) ReadList(date string) ([]Entry, error) {
+	return m.ReadRange(date, date)
+}
+
+// ReadRange returns the entries of every date from the first to the last
+// given one, in order of their dates
+func (m *MemoryDB) ReadRange(from, to string) ([]Entry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entryList := []Entry{}
+	for id, entry := range m.entries {
+		if date := entryDate(id); date >= from && date <= to {
+			entryList = append(entryList, entry)
+		}
+	}
+	sort.Slice(entryList, func(i, j int) bool {
+		return entryList[i].ID < entryList[j].ID
+	})
+	return entryList, nil
+}
+
+// WriteSession writes given session to database in UTC, recording the offset
+// it was recorded in. Writing a session with the same application name and
+// start time again replaces it.
+func (m *MemoryDB) WriteSession(session Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session = session.UTC()
+	if len(session.Cmdline) == 0 {
+		session.Cmdline = nil
+	} else {
+		session.Cmdline = append([]string{}, session.Cmdline...)
+	}
+	m.sessions[memorySessionKey{session.Start.UnixNano(), session.AppName}] = session
+	return nil
+}
+
+// ReadSessions returns the sessions that started within [from, to), ordered
+// by their start time. Their start and end are in UTC.
+func (m *MemoryDB) ReadSessions(from, to time.Time) ([]Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sessionList := []Session{}
+	keys := make([]memorySessionKey, 0, len(m.sessions))
+	for key := range m.sessions {
+		if key.start >= from.UnixNano() && key.start < to.UnixNano() {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].start != keys[j].start {
+			return keys[i].start < keys[j].start
+		}
+		return keys[i].appName < keys[j].appName
+	})
+
+	for _, key := range keys {
+		session := m.sessions[key]
+		session.Cmdline = append([]string(nil), session.Cmdline...)
+		sessionList = append(sessionList, session)
+	}
+	return sessionList, nil
+}
+
+// Close does nothing, the data of a MemoryDB is kept until it is no longer
+// referenced
+func (m *MemoryDB) Close() error {
+	return nil
+}
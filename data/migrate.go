@@ -0,0 +1,363 @@
+package data
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+const (
+	// SchemaVersionKey is the key under which the schema version of a
+	// database is recorded. A database without it which is not empty has
+	// schema version 0.
+	SchemaVersionKey = "meta/schema_version"
+	// ErrSchemaTooNewText is used as prefix text when a database has a
+	// schema version newer than SchemaVersion
+	ErrSchemaTooNewText = "The database was written by a newer version of hourglass, schema version"
+	// BackupFileFormat is the format of the name of the backup taken before a
+	// database is migrated, given its location, its schema version and the
+	// time of the backup. Backups are in Badger's backup format and can be
+	// restored with badger restore.
+	BackupFileFormat = "%s.v%d-%s.bak"
+	// BackupTimeFormat is the time format used in the name of a backup
+	BackupTimeFormat = "20060102-150405"
+
+	// legacyDateFormat is the date format of the keys of the layout in which
+	// entries were keyed by their ID and listed per day under the date
+	legacyDateFormat = "2006-01-02"
+)
+
+// Migration upgrades a database from the schema version before Version to
+// Version. Migrations can be run again after they were interrupted.
+type Migration struct {
+	Version     int
+	Description string
+	apply       func(db *badger.DB) error
+}
+
+// migrations holds every migration, in order of their versions
+var migrations = []Migration{
+	{1, "key entries by date and application name", migrateLegacyLayout},
+	{2, "store sessions in UTC with their offset", migrateSessionsToUTC},
+}
+
+// SchemaVersion is the schema version of the databases written by this
+// version of hourglass
+var SchemaVersion = migrations[len(migrations)-1].Version
+
+// MigrationResult describes the migration of a database. Applied holds the
+// migrations which were applied or, in a dry run, would have been.
+type MigrationResult struct {
+	From       int
+	To         int
+	Applied    []Migration
+	BackupFile string
+}
+
+// MigrateBadgerDB migrates the database at location to SchemaVersion. The
+// database is backed up next to location before any migration is applied.
+// With dryRun set, the migrations are only looked up and nothing is written.
+func MigrateBadgerDB(location string, dryRun bool) (MigrationResult, error) {
+	options := badger.DefaultOptions(location)
+	options.Logger = nil
+	db, err := badger.Open(options)
+	if err != nil {
+		return MigrationResult{}, err
+	}
+
+	result, err := migrate(db, location, dryRun)
+	if closeErr := db.Close(); err == nil {
+		err = closeErr
+	}
+	return result, err
+}
+
+// migrate applies the migrations db is missing, one after the other,
+// recording the schema version after each of them. Unless backupPrefix is
+// empty, db is backed up to a file named after it first.
+func migrate(db *badger.DB, backupPrefix string, dryRun bool) (MigrationResult, error) {
+	var result MigrationResult
+	version, empty, err := readSchemaVersion(db)
+	if err != nil {
+		return result, err
+	}
+	result.From, result.To = version, version
+
+	if empty {
+		result.To = SchemaVersion
+		if dryRun {
+			return result, nil
+		}
+		return result, writeSchemaVersion(db, SchemaVersion)
+	}
+	if version > SchemaVersion {
+		return result, errSchemaTooNew(version, SchemaVersion)
+	}
+
+	result.Applied = pendingMigrations(version)
+	if len(result.Applied) == 0 || dryRun {
+		return result, nil
+	}
+
+	if backupPrefix != "" {
+		result.BackupFile = fmt.Sprintf(BackupFileFormat, backupPrefix, version, time.Now().Format(BackupTimeFormat))
+		if err = backup(db, result.BackupFile); err != nil {
+			result.BackupFile = ""
+			return result, err
+		}
+	}
+
+	for _, m := range result.Applied {
+		if err = m.apply(db); err != nil {
+			return result, fmt.Errorf("migration to schema version %d failed: %v", m.Version, err)
+		}
+		if err = writeSchemaVersion(db, m.Version); err != nil {
+			return result, err
+		}
+		result.To = m.Version
+	}
+	return result, nil
+}
+
+// errSchemaTooNew returns the error for a database with the given schema
+// version when versions up to supported can be read
+func errSchemaTooNew(version, supported int) error {
+	return fmt.Errorf("%s %d, this version supports up to %d", ErrSchemaTooNewText, version, supported)
+}
+
+// pendingMigrations returns the migrations of a database with the given
+// schema version which are not applied yet
+func pendingMigrations(version int) []Migration {
+	var pending []Migration
+	for _, m := range migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// backup writes a full backup of db to a new file with the given name
+func backup(db *badger.DB, name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Backup(f, 0)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
+
+// readSchemaVersion returns the schema version recorded in db and whether
+// db is empty
+func readSchemaVersion(db *badger.DB) (int, bool, error) {
+	version, empty := 0, false
+
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(SchemaVersionKey))
+		if err == badger.ErrKeyNotFound {
+			it := txn.NewIterator(badger.IteratorOptions{})
+			defer it.Close()
+			it.Rewind()
+			empty = !it.Valid()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		return item.Value(func(val []byte) error {
+			version, err = strconv.Atoi(string(val))
+			return err
+		})
+	})
+
+	return version, empty, err
+}
+
+// writeSchemaVersion records the schema version of db
+func writeSchemaVersion(db *badger.DB, version int) error {
+	return db.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(SchemaVersionKey), []byte(strconv.Itoa(version)))
+	})
+}
+
+// batch writes to a database in as few transactions as possible, committing
+// its transaction and starting a new one whenever it grew too big
+type batch struct {
+	db  *badger.DB
+	txn *badger.Txn
+}
+
+func newBatch(db *badger.DB) *batch {
+	return &batch{db: db, txn: db.NewTransaction(true)}
+}
+
+// apply runs fn in the transaction of the batch, committing it first and
+// running fn in a new transaction if it grew too big
+func (b *batch) apply(fn func(txn *badger.Txn) error) error {
+	err := fn(b.txn)
+	if err != badger.ErrTxnTooBig {
+		return err
+	}
+	if err = b.txn.Commit(); err != nil {
+		return err
+	}
+	b.txn = b.db.NewTransaction(true)
+	return fn(b.txn)
+}
+
+// commit commits the transaction of the batch
+func (b *batch) commit() error {
+	return b.txn.Commit()
+}
+
+// discard discards the transaction of the batch unless it was committed
+func (b *batch) discard() {
+	b.txn.Discard()
+}
+
+// legacyLayout holds the keys of the layout in which entries were keyed by
+// their ID and the IDs of a day were listed under the date
+type legacyLayout struct {
+	entries map[string][]byte
+	lists   [][]byte
+}
+
+// empty reports whether no key of the legacy layout is left
+func (l legacyLayout) empty() bool {
+	return len(l.entries) == 0 && len(l.lists) == 0
+}
+
+// readLegacyLayout returns the keys of the legacy layout in db. Entries are
+// returned with their values.
+func readLegacyLayout(db *badger.DB) (legacyLayout, error) {
+	layout := legacyLayout{entries: make(map[string][]byte)}
+
+	err := db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			key := it.Item().KeyCopy(nil)
+			if bytes.HasPrefix(key, []byte(EntryKeyPrefix)) || bytes.HasPrefix(key, []byte(SessionKeyPrefix)) {
+				continue
+			}
+			if len(key) < len(legacyDateFormat) {
+				continue
+			}
+			if _, err := time.Parse(legacyDateFormat, string(key[:len(legacyDateFormat)])); err != nil {
+				continue
+			}
+
+			switch {
+			case len(key) == len(legacyDateFormat):
+				layout.lists = append(layout.lists, key)
+			case string(key[len(legacyDateFormat)]) == EntryIDDateSeparator:
+				value, err := it.Item().ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				layout.entries[string(key)] = value
+			}
+		}
+		return nil
+	})
+
+	return layout, err
+}
+
+// migrateLegacyLayout moves the entries of the legacy layout in db to their
+// keys made up of date and application name and removes the lists of IDs.
+// The lists are not needed as every entry of the legacy layout is moved,
+// including entries whose ID never made it into a list. An entry is only
+// deleted once it is stored under its new key, so the migration can be run
+// again after it was interrupted.
+func migrateLegacyLayout(db *badger.DB) error {
+	layout, err := readLegacyLayout(db)
+	if err != nil || layout.empty() {
+		return err
+	}
+
+	b := newBatch(db)
+	defer b.discard()
+
+	for id, value := range layout.entries {
+		err := b.apply(func(txn *badger.Txn) error {
+			if err := txn.Set(entryKey(id), value); err != nil {
+				return err
+			}
+			return txn.Delete([]byte(id))
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range layout.lists {
+		if err := b.apply(func(txn *badger.Txn) error { return txn.Delete(key) }); err != nil {
+			return err
+		}
+	}
+
+	return b.commit()
+}
+
+// migrateSessionsToUTC stores the sessions of db which were stored in local
+// time in UTC, recording the offset they were stored with
+func migrateSessionsToUTC(db *badger.DB) error {
+	utils := BadgerDBUtilsDefault{}
+	prefix := []byte(SessionKeyPrefix)
+	sessions := make(map[string][]byte)
+
+	err := db.View(func(txn *badger.Txn) error {
+		options := badger.DefaultIteratorOptions
+		options.Prefix = prefix
+		it := txn.NewIterator(options)
+		defer it.Close()
+
+		for it.Rewind(); it.ValidForPrefix(prefix); it.Next() {
+			value, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			session, err := utils.DecodeSession(value)
+			if err != nil {
+				return err
+			}
+			if session.Start.Location() == time.UTC {
+				continue
+			}
+			if value, err = utils.EncodeSession(session.UTC()); err != nil {
+				return err
+			}
+			sessions[string(it.Item().KeyCopy(nil))] = value
+		}
+		return nil
+	})
+	if err != nil || len(sessions) == 0 {
+		return err
+	}
+
+	b := newBatch(db)
+	defer b.discard()
+
+	for key, value := range sessions {
+		err := b.apply(func(txn *badger.Txn) error { return txn.Set([]byte(key), value) })
+		if err != nil {
+			return err
+		}
+	}
+
+	return b.commit()
+}
@@ -0,0 +1,543 @@
+package data_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgraph-io/badger"
+)
+
+// writeLegacyLayout writes the entries to a database at location in the
+// legacy layout, keyed by their ID and listed under their date. Entries
+// from unlisted are written without being listed.
+func writeLegacyLayout(tb testing.TB, location string, listed, unlisted []data.Entry) {
+	tb.Helper()
+	options := badger.DefaultOptions(location)
+	options.Logger = nil
+	db, err := badger.Open(options)
+	if err != nil {
+		tb.Fatalf("No error expected, got %v", err)
+	}
+	defer db.Close()
+
+	lists := make(map[string][]string)
+	err = db.Update(func(txn *badger.Txn) error {
+		for i, entry := range append(append([]data.Entry{}, listed...), unlisted...) {
+			value, err := data.BadgerDBUtilsDefault{}.Encode(entry)
+			if err != nil {
+				return err
+			}
+			if err = txn.Set([]byte(entry.ID), value); err != nil {
+				return err
+			}
+			if i < len(listed) {
+				date := data.BadgerDB{}.GetDate(entry)
+				lists[date] = append(lists[date], entry.ID)
+			}
+		}
+		for date, ids := range lists {
+			var buff bytes.Buffer
+			if err := gob.NewEncoder(&buff).Encode(ids); err != nil {
+				return err
+			}
+			if err := txn.Set([]byte(date), buff.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		tb.Fatalf("No error expected, got %v", err)
+	}
+}
+
+// readLegacyList reads the entries of a day from a database in the legacy
+// layout, as ReadList did before entries were keyed by date
+func readLegacyList(db *badger.DB, date string) ([]data.Entry, error) {
+	var ids []string
+	err := db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get([]byte(date))
+		if err != nil {
+			return err
+		}
+		return item.Value(func(val []byte) error {
+			return gob.NewDecoder(bytes.NewReader(val)).Decode(&ids)
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]data.Entry, 0, len(ids))
+	for _, id := range ids {
+		err := db.View(func(txn *badger.Txn) error {
+			item, err := txn.Get([]byte(id))
+			if err != nil {
+				return err
+			}
+			return item.Value(func(val []byte) error {
+				entry, err := data.BadgerDBUtilsDefault{}.Decode(val)
+				entries = append(entries, entry)
+				return err
+			})
+		})
+		if err != nil {
+			return entries, err
+		}
+	}
+	return entries, nil
+}
+
+func TestMigrateLegacyLayout(t *testing.T) {
+	entryList := createEntryList(3)
+	orphan := data.Entry{ID: data.CreateID("Orphan", stubTime), AppName: "Orphan", Duration: stubDuration}
+
+	t.Run("Entries moved", func(t *testing.T) {
+		defer clean()
+		writeLegacyLayout(t, dbLocation, entryList, []data.Entry{orphan})
+
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		got, err := db.ReadList(db.GetDate(orphan))
+		assertErrorFatal(t, err)
+		want := append(append([]data.Entry{}, entryList...), orphan)
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+
+		err = db.AddEntry(orphan)
+		assertErrorFatal(t, err)
+		entry, err := db.Read(orphan.ID)
+		assertErrorFatal(t, err)
+		if entry.Duration != 2*stubDuration {
+			t.Errorf("got %v, want %v", entry.Duration, 2*stubDuration)
+		}
+	})
+
+	t.Run("Legacy keys removed", func(t *testing.T) {
+		defer clean()
+		writeLegacyLayout(t, dbLocation, entryList, []data.Entry{orphan})
+
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		err = db.Close()
+		assertErrorFatal(t, err)
+
+		options := badger.DefaultOptions(dbLocation)
+		options.Logger = nil
+		raw, err := badger.Open(options)
+		assertErrorFatal(t, err)
+		defer raw.Close()
+
+		err = raw.View(func(txn *badger.Txn) error {
+			for _, key := range []string{orphan.ID, entryList[0].ID, data.BadgerDB{}.GetDate(orphan)} {
+				if _, err := txn.Get([]byte(key)); err != badger.ErrKeyNotFound {
+					t.Errorf("got %v for %q, want %v", err, key, badger.ErrKeyNotFound)
+				}
+			}
+			return nil
+		})
+		assertErrorFatal(t, err)
+	})
+
+	t.Run("Read-only database migrated in snapshot", func(t *testing.T) {
+		defer clean()
+		writeLegacyLayout(t, dbLocation, entryList, nil)
+
+		db, err := data.GetBadgerDBReadOnly(dbLocation, nil)
+		assertErrorFatal(t, err)
+		got, err := db.ReadList(db.GetDate(entryList[0]))
+		db.Close()
+		assertErrorFatal(t, err)
+		if !reflect.DeepEqual(got, entryList) {
+			t.Errorf("got %v, want %v", got, entryList)
+		}
+
+		options := badger.DefaultOptions(dbLocation)
+		options.Logger = nil
+		raw, err := badger.Open(options)
+		assertErrorFatal(t, err)
+		defer raw.Close()
+		legacy, err := readLegacyList(raw, data.BadgerDB{}.GetDate(entryList[0]))
+		assertErrorFatal(t, err)
+		if !reflect.DeepEqual(legacy, entryList) {
+			t.Errorf("got %v, want database left in legacy layout", legacy)
+		}
+	})
+}
+
+// The fixtures in testdata are backups of databases written by earlier
+// versions of hourglass, in Badger's backup format. Each holds the entries
+// of fixtureEntries and, except for the one written before sessions were
+// stored, the sessions of fixtureSessions recorded in fixtureZone:
+//
+//	entries-listed-per-day.bak  entries keyed by their ID and listed per day
+//	sessions-in-local-time.bak  sessions stored in local time
+//	sessions-in-utc.bak         sessions stored in UTC with their offset
+//	entries-keyed-by-date.bak   entries keyed by date and application name,
+//	                            without a schema version
+var (
+	fixtureZone    = time.FixedZone("CET", 60*60)
+	fixtureDay     = time.Date(2021, 3, 14, 9, 0, 0, 0, fixtureZone)
+	fixtureEntries = []data.Entry{
+		{ID: data.CreateID("Editor", fixtureDay), AppName: "Editor", Duration: 2 * time.Hour},
+		{ID: data.CreateID("Mozilla Firefox", fixtureDay), AppName: "Mozilla Firefox", Duration: 30 * time.Minute},
+		{ID: data.CreateID("Editor", fixtureDay.AddDate(0, 0, 1)), AppName: "Editor", Duration: 15 * time.Minute},
+	}
+	fixtureSessions = []data.Session{
+		{
+			AppName: "Editor",
+			Title:   "notes.txt - Editor",
+			Class:   "editor",
+			PID:     4242,
+			Exe:     "/usr/bin/editor",
+			Cmdline: []string{"editor", "notes.txt"},
+			Start:   fixtureDay.UTC(),
+			End:     fixtureDay.Add(2 * time.Hour).UTC(),
+			Offset:  60 * 60,
+		},
+		{
+			AppName: "Mozilla Firefox",
+			Title:   "Hourglass - Mozilla Firefox",
+			Class:   "firefox",
+			PID:     4343,
+			Exe:     "/usr/lib/firefox/firefox",
+			Start:   fixtureDay.Add(2 * time.Hour).UTC(),
+			End:     fixtureDay.Add(150 * time.Minute).UTC(),
+			Offset:  60 * 60,
+		},
+	}
+)
+
+// loadFixture loads the fixture with the given name into a new database at
+// location
+func loadFixture(tb testing.TB, location, name string) {
+	tb.Helper()
+	loadBackup(tb, location, filepath.Join("testdata", name))
+}
+
+// loadBackup loads the backup file with the given name into a new database
+// at location
+func loadBackup(tb testing.TB, location, name string) {
+	tb.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		tb.Fatalf("No error expected, got %v", err)
+	}
+	defer f.Close()
+
+	options := badger.DefaultOptions(location)
+	options.Logger = nil
+	db, err := badger.Open(options)
+	if err != nil {
+		tb.Fatalf("No error expected, got %v", err)
+	}
+	defer db.Close()
+
+	if err = db.Load(f, 16); err != nil {
+		tb.Fatalf("No error expected, got %v", err)
+	}
+}
+
+// assertFixtureContents checks that db holds the entries and sessions of the
+// fixtures, with sessions omitted if withSessions is not set
+func assertFixtureContents(t *testing.T, db data.DB, withSessions bool) {
+	t.Helper()
+	from, to := fixtureEntries[0].ID[:len(data.EntryIDDateFormat)], fixtureEntries[2].ID[:len(data.EntryIDDateFormat)]
+	entries, err := db.ReadRange(from, to)
+	assertErrorFatal(t, err)
+	if !reflect.DeepEqual(entries, fixtureEntries) {
+		t.Errorf("got %v, want %v", entries, fixtureEntries)
+	}
+
+	sessions, err := db.ReadSessions(fixtureDay, fixtureDay.AddDate(0, 0, 1))
+	assertErrorFatal(t, err)
+	want := []data.Session{}
+	if withSessions {
+		want = fixtureSessions
+	}
+	if !reflect.DeepEqual(sessions, want) {
+		t.Errorf("got %v, want %v", sessions, want)
+	}
+}
+
+// setSchemaVersion records the given schema version in the database at
+// location
+func setSchemaVersion(t *testing.T, location string, version int) {
+	t.Helper()
+	options := badger.DefaultOptions(location)
+	options.Logger = nil
+	raw, err := badger.Open(options)
+	assertErrorFatal(t, err)
+	defer raw.Close()
+
+	err = raw.Update(func(txn *badger.Txn) error {
+		return txn.Set([]byte(data.SchemaVersionKey), []byte(fmt.Sprint(version)))
+	})
+	assertErrorFatal(t, err)
+}
+
+// backups returns the backups taken of the test database
+func backups(t *testing.T) []string {
+	t.Helper()
+	files, err := filepath.Glob(dbLocation + ".v*.bak")
+	assertErrorFatal(t, err)
+	return files
+}
+
+func TestMigrate(t *testing.T) {
+	fixtures := []struct {
+		name         string
+		file         string
+		withSessions bool
+	}{
+		{"Entries listed per day", "entries-listed-per-day.bak", false},
+		{"Sessions in local time", "sessions-in-local-time.bak", true},
+		{"Sessions in UTC", "sessions-in-utc.bak", true},
+		{"Entries keyed by date without schema version", "entries-keyed-by-date.bak", true},
+	}
+
+	for _, tt := range fixtures {
+		t.Run(tt.name, func(t *testing.T) {
+			defer clean()
+			loadFixture(t, dbLocation, tt.file)
+
+			db, err := data.GetBadgerDB(dbLocation, nil)
+			assertErrorFatal(t, err)
+			defer db.Close()
+
+			assertFixtureContents(t, db, tt.withSessions)
+
+			version, err := db.SchemaVersion()
+			assertErrorFatal(t, err)
+			if version != data.SchemaVersion {
+				t.Errorf("got %v, want %v", version, data.SchemaVersion)
+			}
+			if got := len(backups(t)); got != 1 {
+				t.Errorf("got %v backups, want 1", got)
+			}
+		})
+	}
+
+	t.Run("Current schema version", func(t *testing.T) {
+		defer clean()
+		loadFixture(t, dbLocation, "entries-keyed-by-date.bak")
+		setSchemaVersion(t, dbLocation, data.SchemaVersion)
+
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		assertFixtureContents(t, db, true)
+		if got := backups(t); len(got) != 0 {
+			t.Errorf("got %v, want no backups", got)
+		}
+	})
+
+	t.Run("Empty database", func(t *testing.T) {
+		defer clean()
+		db, err := data.GetBadgerDB(dbLocation, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+
+		version, err := db.SchemaVersion()
+		assertErrorFatal(t, err)
+		if version != data.SchemaVersion {
+			t.Errorf("got %v, want %v", version, data.SchemaVersion)
+		}
+		if got := backups(t); len(got) != 0 {
+			t.Errorf("got %v, want no backups", got)
+		}
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		defer clean()
+		loadFixture(t, dbLocation, "sessions-in-local-time.bak")
+
+		result, err := data.MigrateBadgerDB(dbLocation, true)
+		assertErrorFatal(t, err)
+		if result.From != 0 || result.To != 0 || len(result.Applied) != data.SchemaVersion || result.BackupFile != "" {
+			t.Errorf("got %+v, want all migrations pending from version 0", result)
+		}
+		if got := backups(t); len(got) != 0 {
+			t.Errorf("got %v, want no backups", got)
+		}
+
+		result, err = data.MigrateBadgerDB(dbLocation, true)
+		assertErrorFatal(t, err)
+		if result.From != 0 || len(result.Applied) != data.SchemaVersion {
+			t.Errorf("got %+v, want database left at version 0", result)
+		}
+	})
+
+	t.Run("Backup restorable", func(t *testing.T) {
+		defer clean()
+		loadFixture(t, dbLocation, "entries-listed-per-day.bak")
+
+		result, err := data.MigrateBadgerDB(dbLocation, false)
+		assertErrorFatal(t, err)
+		if result.From != 0 || result.To != data.SchemaVersion {
+			t.Errorf("got %+v, want migration from 0 to %v", result, data.SchemaVersion)
+		}
+
+		restored := dbLocation + "/restored"
+		loadBackup(t, restored, result.BackupFile)
+
+		db, err := data.GetBadgerDB(restored, nil)
+		assertErrorFatal(t, err)
+		defer db.Close()
+		assertFixtureContents(t, db, false)
+	})
+
+	t.Run("Newer schema version", func(t *testing.T) {
+		defer clean()
+		loadFixture(t, dbLocation, "entries-keyed-by-date.bak")
+		setSchemaVersion(t, dbLocation, data.SchemaVersion+1)
+
+		_, err := data.GetBadgerDB(dbLocation, nil)
+		if err == nil || !strings.HasPrefix(err.Error(), data.ErrSchemaTooNewText) {
+			t.Fatalf("got %v, want error starting with %q", err, data.ErrSchemaTooNewText)
+		}
+
+		_, err = data.GetBadgerDBReadOnly(dbLocation, nil)
+		if err == nil || !strings.HasPrefix(err.Error(), data.ErrSchemaTooNewText) {
+			t.Fatalf("got %v, want error starting with %q", err, data.ErrSchemaTooNewText)
+		}
+	})
+
+	t.Run("Read-only database migrated without backup", func(t *testing.T) {
+		defer clean()
+		loadFixture(t, dbLocation, "sessions-in-local-time.bak")
+
+		db, err := data.GetBadgerDBReadOnly(dbLocation, nil)
+		assertErrorFatal(t, err)
+		assertFixtureContents(t, db, true)
+		db.Close()
+		if got := backups(t); len(got) != 0 {
+			t.Errorf("got %v, want no backups", got)
+		}
+
+		result, err := data.MigrateBadgerDB(dbLocation, true)
+		assertErrorFatal(t, err)
+		if result.From != 0 {
+			t.Errorf("got %+v, want database left at version 0", result)
+		}
+	})
+}
+
+// benchmarkEntries returns entries of the given number of applications on
+// each of the given number of days
+func benchmarkEntries(days, apps int) []data.Entry {
+	entries := make([]data.Entry, 0, days*apps)
+	for day := 0; day < days; day++ {
+		for app := 0; app < apps; app++ {
+			appName := fmt.Sprint("app", app)
+			entries = append(entries, data.Entry{
+				ID:       data.CreateID(appName, stubTime.AddDate(0, 0, day)),
+				AppName:  appName,
+				Duration: stubDuration,
+			})
+		}
+	}
+	return entries
+}
+
+func BenchmarkReadList(b *testing.B) {
+	defer clean()
+	entries := benchmarkEntries(30, 50)
+	db, err := data.GetBadgerDB(dbLocation, nil)
+	if err != nil {
+		b.Fatalf("No error expected, got %v", err)
+	}
+	defer db.Close()
+	for _, entry := range entries {
+		if err := db.AddEntry(entry); err != nil {
+			b.Fatalf("No error expected, got %v", err)
+		}
+	}
+	date := db.GetDate(entries[len(entries)/2])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ReadList(date); err != nil {
+			b.Fatalf("No error expected, got %v", err)
+		}
+	}
+}
+
+func BenchmarkReadListLegacy(b *testing.B) {
+	defer clean()
+	entries := benchmarkEntries(30, 50)
+	writeLegacyLayout(b, dbLocation, entries, nil)
+	options := badger.DefaultOptions(dbLocation)
+	options.Logger = nil
+	db, err := badger.Open(options)
+	if err != nil {
+		b.Fatalf("No error expected, got %v", err)
+	}
+	defer db.Close()
+	date := data.BadgerDB{}.GetDate(entries[len(entries)/2])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := readLegacyList(db, date); err != nil {
+			b.Fatalf("No error expected, got %v", err)
+		}
+	}
+}
+
+func BenchmarkReadRange(b *testing.B) {
+	defer clean()
+	entries := benchmarkEntries(30, 50)
+	db, err := data.GetBadgerDB(dbLocation, nil)
+	if err != nil {
+		b.Fatalf("No error expected, got %v", err)
+	}
+	defer db.Close()
+	for _, entry := range entries {
+		if err := db.AddEntry(entry); err != nil {
+			b.Fatalf("No error expected, got %v", err)
+		}
+	}
+	from, to := db.GetDate(entries[0]), db.GetDate(entries[len(entries)-1])
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := db.ReadRange(from, to); err != nil {
+			b.Fatalf("No error expected, got %v", err)
+		}
+	}
+}
+
+func BenchmarkReadRangeLegacy(b *testing.B) {
+	defer clean()
+	entries := benchmarkEntries(30, 50)
+	writeLegacyLayout(b, dbLocation, entries, nil)
+	options := badger.DefaultOptions(dbLocation)
+	options.Logger = nil
+	db, err := badger.Open(options)
+	if err != nil {
+		b.Fatalf("No error expected, got %v", err)
+	}
+	defer db.Close()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for day := 0; day < 30; day++ {
+			if _, err := readLegacyList(db, stubTime.AddDate(0, 0, day).Format(data.EntryIDDateFormat)); err != nil {
+				b.Fatalf("No error expected, got %v", err)
+			}
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package data
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	// registers the pure Go SQLite driver as "sqlite"
+	_ "modernc.org/sqlite"
+)
+
+const (
+	// SQLiteSchemaVersion is the schema version of the SQLite databases
+	// written by this version of hourglass, recorded as their user_version
+	SQLiteSchemaVersion = 1
+	// SQLiteBusyTimeout is how long a statement waits for another process,
+	// such as a running tracker, to release its lock on the database
+	SQLiteBusyTimeout = 5 * time.Second
+)
+
+// sqliteSchema creates the tables of schema version 1. Daily totals are the
+// entries, one per application and day. Sessions are keyed like in Badger,
+// by their start time in nanoseconds since the epoch and application name.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS daily_totals (
+	id       TEXT PRIMARY KEY,
+	date     TEXT NOT NULL,
+	app_name TEXT NOT NULL,
+	duration INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS daily_totals_date ON daily_totals (date, id);
+CREATE TABLE IF NOT EXISTS sessions (
+	start    INTEGER NOT NULL,
+	app_name TEXT NOT NULL,
+	end      INTEGER NOT NULL,
+	offset   INTEGER NOT NULL,
+	title    TEXT NOT NULL,
+	class    TEXT NOT NULL,
+	pid      INTEGER NOT NULL,
+	exe      TEXT NOT NULL,
+	cmdline  TEXT,
+	PRIMARY KEY (start, app_name)
+) WITHOUT ROWID;
+`
+
+// SQLiteDB represents a SQLite database
+type SQLiteDB struct {
+	db *sql.DB
+}
+
+// AddEntry adds the duration of the given entry to the entry stored under
+// the same ID
+func (s SQLiteDB) AddEntry(entry Entry) error {
+	_, err := s.db.Exec(`
+		INSERT INTO daily_totals (id, date, app_name, duration) VALUES (?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET app_name = excluded.app_name, duration = duration + excluded.duration`,
+		entry.ID, entryDate(entry.ID), entry.AppName, int64(entry.Duration))
+	return err
+}
+
+// Read retrives entry with given id from the database
+func (s SQLiteDB) Read(id string) (Entry, error) {
+	var e Entry
+	var duration int64
+
+	err := s.db.QueryRow(`SELECT id, app_name, duration FROM daily_totals WHERE id = ?`, id).
+		Scan(&e.ID, &e.AppName, &duration)
+	if err == sql.ErrNoRows {
+		return e, ErrNotFound
+	}
+	e.Duration = time.Duration(duration)
+	return e, err
+}
+
+// ReadList returns list of entries matching the given date
+func (s SQLiteDB) ReadList(date string) ([]Entry, error) {
+	return s.ReadRange(date, date)
+}
+
+// ReadRange returns the entries of every date from the first to the last
+// given one, in order of their dates
+func (s SQLiteDB) ReadRange(from, to string) ([]Entry, error) {
+	entryList := []Entry{}
+	rows, err := s.db.Query(`
+		SELECT id, app_name, duration FROM daily_totals
+		WHERE date >= ? AND date <= ? ORDER BY date, id`, from, to)
+	if err != nil {
+		return entryList, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e Entry
+		var duration int64
+		if err = rows.Scan(&e.ID, &e.AppName, &duration); err != nil {
+			return entryList, err
+		}
+		e.Duration = time.Duration(duration)
+		entryList = append(entryList, e)
+	}
+	return entryList, rows.Err()
+}
+
+// WriteSession writes given session to database in UTC, recording the offset
+// it was recorded in. Writing a session with the same application name and
+// start time again replaces it.
+func (s SQLiteDB) WriteSession(session Session) error {
+	session = session.UTC()
+	var cmdline interface{}
+	if len(session.Cmdline) != 0 {
+		value, err := json.Marshal(session.Cmdline)
+		if err != nil {
+			return err
+		}
+		cmdline = string(value)
+	}
+
+	_, err := s.db.Exec(`
+		INSERT OR REPLACE INTO sessions (start, app_name, end, offset, title, class, pid, exe, cmdline)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		session.Start.UnixNano(), session.AppName, session.End.UnixNano(), session.Offset,
+		session.Title, session.Class, session.PID, session.Exe, cmdline)
+	return err
+}
+
+// ReadSessions returns the sessions that started within [from, to), ordered
+// by their start time. Their start and end are in UTC.
+func (s SQLiteDB) ReadSessions(from, to time.Time) ([]Session, error) {
+	sessionList := []Session{}
+	rows, err := s.db.Query(`
+		SELECT start, app_name, end, offset, title, class, pid, exe, cmdline FROM sessions
+		WHERE start >= ? AND start < ? ORDER BY start, app_name`, from.UnixNano(), to.UnixNano())
+	if err != nil {
+		return sessionList, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var session Session
+		var start, end int64
+		var cmdline sql.NullString
+		err = rows.Scan(&start, &session.AppName, &end, &session.Offset,
+			&session.Title, &session.Class, &session.PID, &session.Exe, &cmdline)
+		if err != nil {
+			return sessionList, err
+		}
+		if cmdline.Valid {
+			if err = json.Unmarshal([]byte(cmdline.String), &session.Cmdline); err != nil {
+				return sessionList, err
+			}
+		}
+		session.Start = time.Unix(0, start).UTC()
+		session.End = time.Unix(0, end).UTC()
+		sessionList = append(sessionList, session)
+	}
+	return sessionList, rows.Err()
+}
+
+// SchemaVersion returns the schema version recorded in the database
+func (s SQLiteDB) SchemaVersion() (int, error) {
+	var version int
+	err := s.db.QueryRow(`PRAGMA user_version`).Scan(&version)
+	return version, err
+}
+
+// Close closes connection to database
+func (s SQLiteDB) Close() error {
+	return s.db.Close()
+}
+
+// entryDate returns the date an entry ID starts with
+func entryDate(id string) string {
+	return strings.SplitN(id, EntryIDDateSeparator, 2)[0]
+}
+
+// GetSQLiteDB returns a reference to a SQLiteDB struct for the database file
+// at location, which is created with the tables of SQLiteSchemaVersion if it
+// does not exist yet
+func GetSQLiteDB(location string) (*SQLiteDB, error) {
+	db, err := openSQLite(location, false)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLiteDB{db: db}
+	version, err := s.SchemaVersion()
+	if err == nil && version > SQLiteSchemaVersion {
+		err = errSchemaTooNew(version, SQLiteSchemaVersion)
+	}
+	if err == nil && version < SQLiteSchemaVersion {
+		if _, err = db.Exec(sqliteSchema); err == nil {
+			_, err = db.Exec(fmt.Sprintf(`PRAGMA user_version = %d`, SQLiteSchemaVersion))
+		}
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetSQLiteDBReadOnly returns a reference to a SQLiteDB struct which can only
+// be read from. It can be opened while a tracker writes to the database.
+func GetSQLiteDBReadOnly(location string) (*SQLiteDB, error) {
+	if _, err := os.Stat(location); err != nil {
+		return nil, err
+	}
+
+	db, err := openSQLite(location, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &SQLiteDB{db: db}
+	version, err := s.SchemaVersion()
+	if err == nil && version > SQLiteSchemaVersion {
+		err = errSchemaTooNew(version, SQLiteSchemaVersion)
+	}
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// openSQLite opens the database file at location. A single connection is
+// used, as SQLite allows only one writer at a time anyway. The database is
+// kept in write-ahead log mode, so readers in other processes do not block
+// the tracker.
+func openSQLite(location string, readOnly bool) (*sql.DB, error) {
+	query := url.Values{}
+	query.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", SQLiteBusyTimeout.Milliseconds()))
+	if readOnly {
+		query.Set("mode", "ro")
+	} else {
+		query.Add("_pragma", "journal_mode(WAL)")
+	}
+	dsn := (&url.URL{Scheme: "file", Opaque: (&url.URL{Path: location}).EscapedPath(), RawQuery: query.Encode()}).String()
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err = db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
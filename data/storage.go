@@ -0,0 +1,108 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DriverBadger stores the data in a Badger database directory
+	DriverBadger = "badger"
+	// DriverSQLite stores the data in a SQLite database file
+	DriverSQLite = "sqlite"
+	// DriverMemory keeps the data in memory until the process exits
+	DriverMemory = "memory"
+
+	// ErrUnknownDriverText is used when no storage driver exists for a name
+	ErrUnknownDriverText = "unknown storage driver"
+	// ErrMemoryReadOnlyText is used when a memory database is to be read by
+	// another process than the tracker keeping it
+	ErrMemoryReadOnlyText = "memory storage cannot be read outside the tracker process"
+)
+
+// Drivers lists the names accepted by Open
+var Drivers = []string{DriverBadger, DriverSQLite, DriverMemory}
+
+// Store is a database which has to be closed once it is no longer used
+type Store interface {
+	DB
+	Close() error
+}
+
+// Location returns the location of the database of the given driver in dir
+func Location(driver, dir string) string {
+	if strings.ToLower(driver) == DriverSQLite {
+		return filepath.Join(dir, "data.sqlite")
+	}
+	return filepath.Join(dir, "data")
+}
+
+// Open opens the database of the given driver at location for reading and
+// writing. An empty driver selects DriverBadger.
+func Open(driver, location string) (Store, error) {
+	switch strings.ToLower(driver) {
+	case "", DriverBadger:
+		return store(GetBadgerDB(location, nil))
+	case DriverSQLite:
+		return store(GetSQLiteDB(location))
+	case DriverMemory:
+		return NewMemoryDB(), nil
+	}
+	return nil, errUnknownDriver(driver)
+}
+
+// OpenReadOnly opens the database of the given driver at location for
+// reading, which works while a tracker writes to it. A memory database is
+// not shared between processes and cannot be opened, its entries have to be
+// requested from the tracker over the control socket.
+func OpenReadOnly(driver, location string) (Store, error) {
+	switch strings.ToLower(driver) {
+	case "", DriverBadger:
+		return store(GetBadgerDBReadOnly(location, nil))
+	case DriverSQLite:
+		return store(GetSQLiteDBReadOnly(location))
+	case DriverMemory:
+		return nil, errors.New(ErrMemoryReadOnlyText)
+	}
+	return nil, errUnknownDriver(driver)
+}
+
+// Migrate migrates the database of the given driver at location to the
+// schema version of the driver, like MigrateBadgerDB. SQLite databases have
+// a single schema version so far, which they are created with.
+func Migrate(driver, location string, dryRun bool) (MigrationResult, error) {
+	switch strings.ToLower(driver) {
+	case "", DriverBadger:
+		return MigrateBadgerDB(location, dryRun)
+	case DriverSQLite:
+		open := GetSQLiteDB
+		if dryRun {
+			open = GetSQLiteDBReadOnly
+		}
+		db, err := open(location)
+		if err != nil {
+			return MigrationResult{}, err
+		}
+		defer db.Close()
+		version, err := db.SchemaVersion()
+		return MigrationResult{From: version, To: version}, err
+	case DriverMemory:
+		return MigrationResult{}, nil
+	}
+	return MigrationResult{}, errUnknownDriver(driver)
+}
+
+// store returns s unless opening it failed, so that callers never get a
+// non-nil Store holding a nil database
+func store(s Store, err error) (Store, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func errUnknownDriver(driver string) error {
+	return fmt.Errorf("%s: %q", ErrUnknownDriverText, driver)
+}
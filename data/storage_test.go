@@ -0,0 +1,92 @@
+package data_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/data/datatest"
+
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// stores opens an empty database of every driver, removing it once the
+// test finished
+var stores = []struct {
+	driver string
+	open   func(t *testing.T) data.Store
+}{
+	{data.DriverBadger, func(t *testing.T) data.Store {
+		t.Cleanup(func() { clean() })
+		db, err := data.Open(data.DriverBadger, dbLocation)
+		assertErrorFatal(t, err)
+		return db
+	}},
+	{data.DriverSQLite, func(t *testing.T) data.Store {
+		t.Cleanup(func() { clean() })
+		db, err := data.Open(data.DriverSQLite, dbLocation+".sqlite")
+		assertErrorFatal(t, err)
+		return db
+	}},
+	{data.DriverMemory, func(t *testing.T) data.Store {
+		db, err := data.Open(data.DriverMemory, "")
+		assertErrorFatal(t, err)
+		return db
+	}},
+}
+
+func TestOpen(t *testing.T) {
+	t.Run("Unknown driver", func(t *testing.T) {
+		db, err := data.Open("csv", dbLocation)
+		if db != nil || err == nil || !strings.HasPrefix(err.Error(), data.ErrUnknownDriverText) {
+			t.Errorf("got %v, %v, want error starting with %q", db, err, data.ErrUnknownDriverText)
+		}
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		assertEqual(t, data.Location(data.DriverBadger, "/home/hourglass"), "/home/hourglass/data")
+		assertEqual(t, data.Location(data.DriverSQLite, "/home/hourglass"), "/home/hourglass/data.sqlite")
+	})
+
+	t.Run("Memory read-only", func(t *testing.T) {
+		db, err := data.OpenReadOnly(data.DriverMemory, "")
+		if db != nil || err == nil || err.Error() != data.ErrMemoryReadOnlyText {
+			t.Errorf("got %v, %v, want error %q", db, err, data.ErrMemoryReadOnlyText)
+		}
+	})
+
+	t.Run("SQLite read-only", func(t *testing.T) {
+		defer clean()
+		location := dbLocation + ".sqlite"
+		_, err := data.OpenReadOnly(data.DriverSQLite, location)
+		assertNotNil(t, err)
+
+		db, err := data.Open(data.DriverSQLite, location)
+		assertErrorFatal(t, err)
+		defer db.Close()
+		entry := createEntry()
+		err = db.AddEntry(entry)
+		assertErrorFatal(t, err)
+
+		reader, err := data.OpenReadOnly(data.DriverSQLite, location)
+		assertErrorFatal(t, err)
+		defer reader.Close()
+		got, err := reader.Read(entry.ID)
+		assertErrorFatal(t, err)
+		if !reflect.DeepEqual(got, entry) {
+			t.Errorf("got %v, want %v", got, entry)
+		}
+		assertNotNil(t, reader.AddEntry(entry))
+	})
+}
+
+func TestConformance(t *testing.T) {
+	for _, store := range stores {
+		t.Run(store.driver, func(t *testing.T) {
+			datatest.TestDB(t, func(t *testing.T) data.DB {
+				db := store.open(t)
+				t.Cleanup(func() { db.Close() })
+				return db
+			})
+		})
+	}
+}
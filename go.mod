@@ -1,10 +1,14 @@
 module github.com/shldhll/hourglass
 
-go 1.15
+go 1.16
 
 require (
 	github.com/dgraph-io/badger v1.6.2
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/jezek/xgb v1.1.1
 	github.com/mitchellh/go-homedir v1.1.0
 	github.com/spf13/cobra v1.1.3
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.7.0
+	modernc.org/sqlite v1.17.3
 )
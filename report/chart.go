@@ -0,0 +1,285 @@
+package report
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+)
+
+const (
+	chartWidth      = 800
+	barHeight       = 22
+	barGap          = 6
+	labelWidth      = 200
+	valueWidth      = 80
+	stackHeight     = 240
+	stackAxisHeight = 20
+	heatCellWidth   = 26
+	heatCellHeight  = 18
+	heatLabelWidth  = 90
+
+	// OtherAppName labels the apps grouped together in stacked charts
+	OtherAppName = "Other"
+	// MaxStackedApps is the number of apps shown separately in stacked charts
+	MaxStackedApps = 8
+)
+
+// palette holds the colors assigned to apps in order of usage
+var palette = []string{
+	"#4e79a7", "#f28e2b", "#e15759", "#76b7b2",
+	"#59a14f", "#edc948", "#b07aa1", "#ff9da7",
+}
+
+const otherColor = "#bab0ac"
+
+// appTotal represents the summed duration of an app
+type appTotal struct {
+	AppName  string
+	Duration time.Duration
+}
+
+// appTotals sums the rows per app, in order of descending duration
+func appTotals(rows []Row) []appTotal {
+	index := make(map[string]int)
+	totals := []appTotal{}
+	for _, row := range rows {
+		i, ok := index[row.AppName]
+		if !ok {
+			i = len(totals)
+			index[row.AppName] = i
+			totals = append(totals, appTotal{AppName: row.AppName})
+		}
+		totals[i].Duration += row.Duration
+	}
+
+	sort.SliceStable(totals, func(i, j int) bool {
+		if totals[i].Duration != totals[j].Duration {
+			return totals[i].Duration > totals[j].Duration
+		}
+		return totals[i].AppName < totals[j].AppName
+	})
+	return totals
+}
+
+// activeRows returns the rows of time spent in applications, leaving out
+// idle, paused, sleep and lock rows
+func activeRows(rows []Row) []Row {
+	active := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		if data.Active(row.AppName) {
+			active = append(active, row)
+		}
+	}
+	return active
+}
+
+// appColors assigns a palette color to the most used apps. Apps missing from
+// the returned map are drawn as OtherAppName.
+func appColors(totals []appTotal) map[string]string {
+	colors := make(map[string]string)
+	for i, total := range totals {
+		if i == MaxStackedApps || i == len(palette) {
+			break
+		}
+		colors[total.AppName] = palette[i]
+	}
+	return colors
+}
+
+// AppBarChart renders a horizontal bar per app showing its total duration.
+// Idle, paused, sleep and lock rows are not drawn.
+func AppBarChart(rows []Row) template.HTML {
+	totals := appTotals(activeRows(rows))
+	if len(totals) == 0 {
+		return ""
+	}
+
+	colors := appColors(totals)
+	maxDuration := totals[0].Duration
+	barArea := float64(chartWidth - labelWidth - valueWidth)
+	height := len(totals) * (barHeight + barGap)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Time per application">`, chartWidth, height)
+	for i, total := range totals {
+		y := i * (barHeight + barGap)
+		width := 0.0
+		if maxDuration > 0 {
+			width = barArea * float64(total.Duration) / float64(maxDuration)
+		}
+		color, ok := colors[total.AppName]
+		if !ok {
+			color = otherColor
+		}
+
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="end" dominant-baseline="middle">%s</text>`,
+			labelWidth-8, y+barHeight/2, html.EscapeString(truncate(total.AppName, 28)))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%.1f" height="%d" fill="%s"><title>%s: %s</title></rect>`,
+			labelWidth, y, width, barHeight, color, html.EscapeString(total.AppName), FormatDuration(total.Duration))
+		fmt.Fprintf(&b, `<text class="muted" x="%.1f" y="%d" dominant-baseline="middle">%s</text>`,
+			float64(labelWidth)+width+6, y+barHeight/2, FormatDuration(total.Duration))
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// DayStackedChart renders a vertical bar per day of the range, stacked by app.
+// Idle, paused, sleep and lock rows are not drawn.
+func DayStackedChart(rows []Row, r Range) template.HTML {
+	rows = activeRows(rows)
+	totals := appTotals(rows)
+	days := r.Days()
+	if len(totals) == 0 || len(days) == 0 {
+		return ""
+	}
+
+	colors := appColors(totals)
+	dayIndex := make(map[string]int)
+	for i, day := range days {
+		dayIndex[day.Format(DateFormat)] = i
+	}
+
+	// segments[day][color] keeps per day durations in palette order
+	segments := make([]map[string]time.Duration, len(days))
+	dayTotals := make([]time.Duration, len(days))
+	var maxDuration time.Duration
+	for _, row := range rows {
+		i, ok := dayIndex[row.Date]
+		if !ok {
+			continue
+		}
+		if segments[i] == nil {
+			segments[i] = make(map[string]time.Duration)
+		}
+		color, ok := colors[row.AppName]
+		if !ok {
+			color = otherColor
+		}
+		segments[i][color] += row.Duration
+		dayTotals[i] += row.Duration
+		if dayTotals[i] > maxDuration {
+			maxDuration = dayTotals[i]
+		}
+	}
+
+	order := append(append([]string{}, palette...), otherColor)
+	slot := float64(chartWidth) / float64(len(days))
+	width := slot * 0.7
+	labelEvery := (len(days) + 13) / 14
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Time per day">`, chartWidth, stackHeight+stackAxisHeight)
+	for i, day := range days {
+		x := slot*float64(i) + (slot-width)/2
+		y := float64(stackHeight)
+		for _, color := range order {
+			duration := segments[i][color]
+			if duration == 0 || maxDuration == 0 {
+				continue
+			}
+			height := float64(stackHeight) * float64(duration) / float64(maxDuration)
+			y -= height
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"/>`, x, y, width, height, color)
+		}
+		if dayTotals[i] > 0 {
+			fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="transparent"><title>%s: %s</title></rect>`,
+				x, y, width, float64(stackHeight)-y, day.Format(DateFormat), FormatDuration(dayTotals[i]))
+		}
+		if i%labelEvery == 0 {
+			fmt.Fprintf(&b, `<text class="muted" x="%.1f" y="%d" text-anchor="middle">%s</text>`,
+				slot*float64(i)+slot/2, stackHeight+stackAxisHeight-4, day.Format("Jan 2"))
+		}
+	}
+	b.WriteString(`</svg>`)
+
+	b.WriteString(`<ul class="legend">`)
+	for _, total := range totals {
+		if color, ok := colors[total.AppName]; ok {
+			fmt.Fprintf(&b, `<li><span class="swatch" style="background:%s"></span>%s</li>`, color, html.EscapeString(total.AppName))
+		}
+	}
+	if len(totals) > len(colors) {
+		fmt.Fprintf(&b, `<li><span class="swatch" style="background:%s"></span>%s</li>`, otherColor, OtherAppName)
+	}
+	b.WriteString(`</ul>`)
+
+	return template.HTML(b.String())
+}
+
+// HourHeatmap renders a grid with a row per day of the range and a column per
+// hour of the day, shaded by the time spent in applications during that hour.
+// Columns start at the hour the days of the range start at. Idle, paused,
+// sleep and lock sessions are not counted.
+func HourHeatmap(sessions []data.Session, r Range) template.HTML {
+	days := r.Days()
+	if len(days) == 0 {
+		return ""
+	}
+
+	dayIndex := make(map[string]int)
+	for i, day := range days {
+		dayIndex[day.Format(DateFormat)] = i
+	}
+
+	location := r.From.Location()
+	firstHour := int(r.DayStart / time.Hour)
+	cells := make([][24]time.Duration, len(days))
+	for _, session := range sessions {
+		if !data.Active(session.AppName) {
+			continue
+		}
+
+		start := session.Start.In(location)
+		end := session.End.In(location)
+		for start.Before(end) {
+			next := time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+1, 0, 0, 0, location)
+			if next.After(end) {
+				next = end
+			}
+			if i, ok := dayIndex[data.Day(start, r.DayStart).Format(DateFormat)]; ok {
+				cells[i][(start.Hour()-firstHour+24)%24] += next.Sub(start)
+			}
+			start = next
+		}
+	}
+
+	height := (len(days) + 1) * heatCellHeight
+	width := heatLabelWidth + 24*heatCellWidth
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="chart" viewBox="0 0 %d %d" role="img" aria-label="Activity per hour of the day">`, width, height)
+	for column := 0; column < 24; column += 3 {
+		fmt.Fprintf(&b, `<text class="muted" x="%d" y="%d">%02d</text>`, heatLabelWidth+column*heatCellWidth, heatCellHeight-5, (firstHour+column)%24)
+	}
+	for i, day := range days {
+		y := (i + 1) * heatCellHeight
+		fmt.Fprintf(&b, `<text class="muted" x="0" y="%d" dominant-baseline="middle">%s</text>`, y+heatCellHeight/2, day.Format("Mon Jan 2"))
+		for column, duration := range cells[i] {
+			opacity := float64(duration) / float64(time.Hour)
+			if opacity > 1 {
+				opacity = 1
+			}
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="%s" fill-opacity="%.2f" stroke="#fff"><title>%s %02d:00: %s</title></rect>`,
+				heatLabelWidth+column*heatCellWidth, y, heatCellWidth, heatCellHeight, palette[0], 0.05+0.95*opacity,
+				day.Format(DateFormat), (firstHour+column)%24, FormatDuration(duration))
+		}
+	}
+	b.WriteString(`</svg>`)
+
+	return template.HTML(b.String())
+}
+
+// truncate shortens s to at most n runes, marking the cut with an ellipsis
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,148 @@
+package report_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/report"
+
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stubRange = report.Range{From: date(2021, 3, 16), To: date(2021, 3, 17)}
+
+func TestAppBarChart(t *testing.T) {
+	t.Run("One bar per app", func(t *testing.T) {
+		rows := append([]report.Row{{Date: "2021-03-17", AppName: "Editor", Duration: time.Hour}}, stubRows...)
+		got := string(report.AppBarChart(rows))
+
+		if n := strings.Count(got, "<rect"); n != 2 {
+			t.Errorf("got %d bars, want 2", n)
+		}
+		for _, want := range []string{"Editor", "02:30:02", "Browser, &#34;beta&#34;"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%q not found in chart", want)
+			}
+		}
+	})
+
+	t.Run("No idle bar", func(t *testing.T) {
+		rows := append([]report.Row{{Date: "2021-03-17", AppName: data.IdleAppName, Duration: time.Hour}}, stubRows...)
+		got := string(report.AppBarChart(rows))
+
+		if n := strings.Count(got, "<rect"); n != 2 {
+			t.Errorf("got %d bars, want 2", n)
+		}
+		if strings.Contains(got, data.IdleAppName) {
+			t.Errorf("%q bar drawn", data.IdleAppName)
+		}
+	})
+
+	t.Run("No rows", func(t *testing.T) {
+		if got := report.AppBarChart(nil); got != "" {
+			t.Errorf("got %q, want empty chart", got)
+		}
+	})
+}
+
+func TestDayStackedChart(t *testing.T) {
+	t.Run("Stacks apps per day", func(t *testing.T) {
+		got := string(report.DayStackedChart(stubRows, stubRange))
+
+		for _, want := range []string{"Mar 16", "Mar 17", "2021-03-16: 01:30:02", "2021-03-17: 00:00:45"} {
+			if !strings.Contains(got, want) {
+				t.Errorf("%q not found in chart", want)
+			}
+		}
+	})
+
+	t.Run("No idle segments", func(t *testing.T) {
+		rows := append([]report.Row{{Date: "2021-03-16", AppName: data.PausedAppName, Duration: time.Hour}}, stubRows...)
+		got := string(report.DayStackedChart(rows, stubRange))
+
+		if strings.Contains(got, data.PausedAppName) {
+			t.Errorf("%q drawn in chart", data.PausedAppName)
+		}
+		if want := "2021-03-16: 01:30:02"; !strings.Contains(got, want) {
+			t.Errorf("%q not found in chart", want)
+		}
+	})
+
+	t.Run("Groups apps beyond the palette", func(t *testing.T) {
+		rows := []report.Row{}
+		for i := 0; i <= report.MaxStackedApps; i++ {
+			rows = append(rows, report.Row{Date: "2021-03-16", AppName: fmt.Sprint("app", i), Duration: time.Duration(i+1) * time.Minute})
+		}
+		got := string(report.DayStackedChart(rows, stubRange))
+
+		if !strings.Contains(got, report.OtherAppName) {
+			t.Errorf("%q not found in legend", report.OtherAppName)
+		}
+		if strings.Contains(got, ">app0<") {
+			t.Errorf("least used app listed in legend")
+		}
+	})
+}
+
+func TestHourHeatmap(t *testing.T) {
+	sessions := []data.Session{
+		{AppName: "Editor", Start: date(2021, 3, 16).Add(9*time.Hour + 30*time.Minute), End: date(2021, 3, 16).Add(11 * time.Hour)},
+		{AppName: data.IdleAppName, Start: date(2021, 3, 17).Add(9 * time.Hour), End: date(2021, 3, 17).Add(10 * time.Hour)},
+	}
+	got := string(report.HourHeatmap(sessions, stubRange))
+
+	if n := strings.Count(got, "<rect"); n != 2*24 {
+		t.Errorf("got %d cells, want %d", n, 2*24)
+	}
+	for _, want := range []string{"2021-03-16 09:00: 00:30:00", "2021-03-16 10:00: 01:00:00", "2021-03-17 09:00: 00:00:00"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not found in heatmap", want)
+		}
+	}
+
+	t.Run("Day start", func(t *testing.T) {
+		r := stubRange
+		r.DayStart = 4 * time.Hour
+		late := []data.Session{{AppName: "Editor", Start: date(2021, 3, 17).Add(2 * time.Hour), End: date(2021, 3, 17).Add(3 * time.Hour)}}
+		got := string(report.HourHeatmap(late, r))
+
+		if want := "2021-03-16 02:00: 01:00:00"; !strings.Contains(got, want) {
+			t.Errorf("%q not found in heatmap", want)
+		}
+		if want := ">04</text>"; !strings.Contains(got, want) {
+			t.Errorf("%q not found in heatmap", want)
+		}
+	})
+}
+
+func TestHTMLExporterOffline(t *testing.T) {
+	exporter, err := report.GetExporter(report.FormatHTML)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	var buff bytes.Buffer
+	err = exporter.Export(&buff, report.Report{
+		Range:    stubRange,
+		Rows:     stubRows,
+		Sessions: []data.Session{{AppName: "Editor", Start: date(2021, 3, 16), End: date(2021, 3, 16).Add(time.Hour)}},
+	})
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	got := buff.String()
+
+	for _, unwanted := range []string{"http://", "https://", "<link", "<script"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("%q found in offline report", unwanted)
+		}
+	}
+	if strings.Count(got, "<svg") != 3 {
+		t.Errorf("got %d charts, want 3", strings.Count(got, "<svg"))
+	}
+	if !strings.Contains(got, "border-collapse") {
+		t.Errorf("stylesheet not embedded")
+	}
+}
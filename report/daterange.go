@@ -0,0 +1,241 @@
+package report
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+)
+
+const (
+	// DateFormat is the ISO date format accepted and produced by the parser
+	DateFormat = "2006-01-02"
+
+	// ErrDateExprPrefixText is used as prefix text for unparseable expressions
+	ErrDateExprPrefixText = "invalid date expression"
+	// ErrRangeOrderText is used when the start of a range lies after its end
+	ErrRangeOrderText = "start date is after end date"
+)
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+// Range represents an inclusive range of days. Days start DayStart after
+// midnight.
+type Range struct {
+	From     time.Time
+	To       time.Time
+	DayStart time.Duration
+}
+
+// Start returns the time the first day of the range starts at
+func (r Range) Start() time.Time {
+	return data.StartOfDay(r.From, r.DayStart)
+}
+
+// End returns the time the day after the range starts at
+func (r Range) End() time.Time {
+	return data.StartOfDay(r.To.AddDate(0, 0, 1), r.DayStart)
+}
+
+// Clip returns the sessions overlapping the range, cut off at its start and
+// end. Sessions are expected in the zone of the range.
+func (r Range) Clip(sessions []data.Session) []data.Session {
+	start, end := r.Start(), r.End()
+	clipped := make([]data.Session, 0, len(sessions))
+	for _, session := range sessions {
+		if session.Start.Before(start) {
+			if !session.End.After(start) {
+				continue
+			}
+			session.Start = start
+		}
+		if !session.Start.Before(end) {
+			continue
+		}
+		if session.End.After(end) {
+			session.End = end
+		}
+		clipped = append(clipped, session)
+	}
+	return clipped
+}
+
+// Days returns the start of every day in the range, in order
+func (r Range) Days() []time.Time {
+	days := []time.Time{}
+	for day := r.From; !day.After(r.To); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+	return days
+}
+
+// ParseRange parses the given start and end expressions relative to now. An
+// empty expression defaults to today. Expressions naming a period, such as
+// "this-month", resolve to the first day of the period when used as start
+// and to the last day when used as end.
+func ParseRange(from, to string, now time.Time) (Range, error) {
+	var r Range
+
+	start, _, err := parseExpr(from, now)
+	if err != nil {
+		return r, err
+	}
+
+	_, end, err := parseExpr(to, now)
+	if err != nil {
+		return r, err
+	}
+
+	if start.After(end) {
+		return r, fmt.Errorf("%s: %s > %s", ErrRangeOrderText, start.Format(DateFormat), end.Format(DateFormat))
+	}
+
+	r.From = start
+	r.To = end
+	return r, nil
+}
+
+// ParseDate parses a single date expression relative to now and returns the
+// start of the day it refers to. Accepted expressions are ISO dates
+// (2006-01-02), "today", "yesterday", "tomorrow", weekday names optionally
+// prefixed by "last" or "this", offsets such as "-7d", "-2w" or "-1m", and
+// the periods "this-week", "last-week", "this-month", "last-month",
+// "this-year" and "last-year".
+func ParseDate(expr string, now time.Time) (time.Time, error) {
+	start, _, err := parseExpr(expr, now)
+	return start, err
+}
+
+// parseExpr returns the first and the last day the expression refers to
+func parseExpr(expr string, now time.Time) (time.Time, time.Time, error) {
+	today := startOfDay(now)
+	normalized := strings.ToLower(strings.Join(strings.FieldsFunc(expr, isSeparator), "-"))
+
+	switch normalized {
+	case "", "today":
+		return today, today, nil
+	case "yesterday":
+		day := today.AddDate(0, 0, -1)
+		return day, day, nil
+	case "tomorrow":
+		day := today.AddDate(0, 0, 1)
+		return day, day, nil
+	case "this-week":
+		start := startOfWeek(today)
+		return start, start.AddDate(0, 0, 6), nil
+	case "last-week":
+		start := startOfWeek(today).AddDate(0, 0, -7)
+		return start, start.AddDate(0, 0, 6), nil
+	case "this-month":
+		start := today.AddDate(0, 0, 1-today.Day())
+		return start, start.AddDate(0, 1, -1), nil
+	case "last-month":
+		start := today.AddDate(0, 0, 1-today.Day()).AddDate(0, -1, 0)
+		return start, start.AddDate(0, 1, -1), nil
+	case "this-year":
+		start := today.AddDate(0, 0, 1-today.YearDay())
+		return start, start.AddDate(1, 0, -1), nil
+	case "last-year":
+		start := today.AddDate(0, 0, 1-today.YearDay()).AddDate(-1, 0, 0)
+		return start, start.AddDate(1, 0, -1), nil
+	}
+
+	if day, ok := parseWeekday(normalized, today); ok {
+		return day, day, nil
+	}
+
+	if day, ok := parseOffset(normalized, today); ok {
+		return day, day, nil
+	}
+
+	day, err := time.ParseInLocation(DateFormat, expr, now.Location())
+	if err != nil {
+		return day, day, fmt.Errorf("%s: %q", ErrDateExprPrefixText, expr)
+	}
+	return day, day, nil
+}
+
+// parseWeekday resolves "monday" and "this-monday" to the most recent monday
+// on or before today and "last-monday" to the most recent one before today
+func parseWeekday(expr string, today time.Time) (time.Time, bool) {
+	name := expr
+	strict := false
+	if strings.HasPrefix(expr, "last-") {
+		name = strings.TrimPrefix(expr, "last-")
+		strict = true
+	} else if strings.HasPrefix(expr, "this-") {
+		name = strings.TrimPrefix(expr, "this-")
+	}
+
+	weekday, ok := weekdays[name]
+	if !ok {
+		return today, false
+	}
+
+	diff := (int(today.Weekday()) - int(weekday) + 7) % 7
+	if diff == 0 && strict {
+		diff = 7
+	}
+	return today.AddDate(0, 0, -diff), true
+}
+
+// parseOffset resolves offsets such as "-7d", "+1w" or "-3m" against today
+func parseOffset(expr string, today time.Time) (time.Time, bool) {
+	if len(expr) < 3 || (expr[0] != '-' && expr[0] != '+') {
+		return today, false
+	}
+
+	n, err := strconv.Atoi(expr[1 : len(expr)-1])
+	if err != nil {
+		return today, false
+	}
+	if expr[0] == '-' {
+		n = -n
+	}
+
+	switch expr[len(expr)-1] {
+	case 'd':
+		return today.AddDate(0, 0, n), true
+	case 'w':
+		return today.AddDate(0, 0, 7*n), true
+	case 'm':
+		return addMonths(today, n), true
+	case 'y':
+		return addMonths(today, 12*n), true
+	}
+	return today, false
+}
+
+// addMonths moves day by n months. Days missing from the target month, such
+// as the 31st, become its last day instead of overflowing into the next one.
+func addMonths(day time.Time, n int) time.Time {
+	first := time.Date(day.Year(), day.Month()+time.Month(n), 1, 0, 0, 0, 0, day.Location())
+	last := first.AddDate(0, 1, -1).Day()
+	if d := day.Day(); d < last {
+		last = d
+	}
+	return first.AddDate(0, 0, last-1)
+}
+
+func isSeparator(r rune) bool {
+	return r == ' ' || r == '_'
+}
+
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// startOfWeek returns the monday of the week the given day belongs to
+func startOfWeek(day time.Time) time.Time {
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
@@ -0,0 +1,205 @@
+package report_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/report"
+
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// stubNow is a Wednesday
+var stubNow = time.Date(2021, 3, 17, 15, 4, 5, 0, time.UTC)
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+func TestParseDate(t *testing.T) {
+	cases := map[string]time.Time{
+		"":               date(2021, 3, 17),
+		"today":          date(2021, 3, 17),
+		"yesterday":      date(2021, 3, 16),
+		"tomorrow":       date(2021, 3, 18),
+		"2021-01-05":     date(2021, 1, 5),
+		"-7d":            date(2021, 3, 10),
+		"+2d":            date(2021, 3, 19),
+		"-2w":            date(2021, 3, 3),
+		"-1m":            date(2021, 2, 17),
+		"-1y":            date(2020, 3, 17),
+		"monday":         date(2021, 3, 15),
+		"this monday":    date(2021, 3, 15),
+		"last monday":    date(2021, 3, 15),
+		"last-monday":    date(2021, 3, 15),
+		"wednesday":      date(2021, 3, 17),
+		"last wednesday": date(2021, 3, 10),
+		"Last Thursday":  date(2021, 3, 11),
+		"this-week":      date(2021, 3, 15),
+		"last-week":      date(2021, 3, 8),
+		"this-month":     date(2021, 3, 1),
+		"last-month":     date(2021, 2, 1),
+		"this-year":      date(2021, 1, 1),
+		"last_year":      date(2020, 1, 1),
+	}
+
+	for expr, want := range cases {
+		t.Run(expr, func(t *testing.T) {
+			got, err := report.ParseDate(expr, stubNow)
+			if err != nil {
+				t.Fatalf("No error expected, got %v", err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("Month offsets clamped to the last day", func(t *testing.T) {
+		clamped := []struct {
+			expr string
+			now  time.Time
+			want time.Time
+		}{
+			{"-1m", date(2021, 3, 31), date(2021, 2, 28)},
+			{"+1m", date(2021, 1, 31), date(2021, 2, 28)},
+			{"-1m", date(2020, 3, 31), date(2020, 2, 29)},
+			{"+2m", date(2021, 12, 31), date(2022, 2, 28)},
+			{"-1y", date(2020, 2, 29), date(2019, 2, 28)},
+		}
+		for _, c := range clamped {
+			got, err := report.ParseDate(c.expr, c.now)
+			if err != nil {
+				t.Fatalf("No error expected, got %v", err)
+			}
+			if !got.Equal(c.want) {
+				t.Errorf("%q from %v: got %v, want %v", c.expr, c.now, got, c.want)
+			}
+		}
+	})
+
+	t.Run("Invalid expressions", func(t *testing.T) {
+		for _, expr := range []string{"someday", "2021-13-01", "-7x", "last", "-d"} {
+			_, err := report.ParseDate(expr, stubNow)
+			if err == nil || !strings.HasPrefix(err.Error(), report.ErrDateExprPrefixText) {
+				t.Errorf("%q: got %v, want %q error", expr, err, report.ErrDateExprPrefixText)
+			}
+		}
+	})
+}
+
+func TestParseRange(t *testing.T) {
+	t.Run("Defaults to today", func(t *testing.T) {
+		got, err := report.ParseRange("", "", stubNow)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		want := report.Range{From: date(2021, 3, 17), To: date(2021, 3, 17)}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Periods expand to their last day as end", func(t *testing.T) {
+		got, err := report.ParseRange("last-month", "last-month", stubNow)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		want := report.Range{From: date(2021, 2, 1), To: date(2021, 2, 28)}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Start after end", func(t *testing.T) {
+		_, err := report.ParseRange("today", "yesterday", stubNow)
+		if err == nil || !strings.HasPrefix(err.Error(), report.ErrRangeOrderText) {
+			t.Errorf("got %v, want %q error", err, report.ErrRangeOrderText)
+		}
+	})
+}
+
+func TestRangeDays(t *testing.T) {
+	t.Run("Every day exactly once", func(t *testing.T) {
+		r, err := report.ParseRange("-6d", "today", stubNow)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+
+		got := r.Days()
+		if len(got) != 7 {
+			t.Fatalf("got %d days, want 7", len(got))
+		}
+		for i, day := range got {
+			if want := date(2021, 3, 11+i); !day.Equal(want) {
+				t.Errorf("got %v, want %v", day, want)
+			}
+		}
+	})
+
+	t.Run("Across month boundary", func(t *testing.T) {
+		r := report.Range{From: date(2021, 1, 30), To: date(2021, 2, 2)}
+		got := r.Days()
+		want := []time.Time{date(2021, 1, 30), date(2021, 1, 31), date(2021, 2, 1), date(2021, 2, 2)}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Across DST change", func(t *testing.T) {
+		location, err := time.LoadLocation("Europe/Berlin")
+		if err != nil {
+			t.Skip("timezone database not available")
+		}
+		now := time.Date(2021, 3, 29, 12, 0, 0, 0, location)
+		r, err := report.ParseRange("-3d", "today", now)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		got := r.Days()
+		if len(got) != 4 {
+			t.Fatalf("got %d days, want 4", len(got))
+		}
+		for _, day := range got {
+			if day.Hour() != 0 {
+				t.Errorf("day %v does not start at midnight", day)
+			}
+		}
+	})
+}
+
+func TestRangeClip(t *testing.T) {
+	r := report.Range{From: date(2021, 3, 15), To: date(2021, 3, 15)}
+	at := func(hour int) time.Time {
+		return date(2021, 3, 15).Add(time.Duration(hour) * time.Hour)
+	}
+	sessions := []data.Session{
+		{AppName: "before", Start: at(-3), End: at(-1)},
+		{AppName: "into", Start: at(-2), End: at(1)},
+		{AppName: "within", Start: at(9), End: at(10)},
+		{AppName: "out of", Start: at(23), End: at(26)},
+		{AppName: "after", Start: at(24), End: at(25)},
+	}
+
+	got := r.Clip(sessions)
+	want := []data.Session{
+		{AppName: "into", Start: at(0), End: at(1)},
+		{AppName: "within", Start: at(9), End: at(10)},
+		{AppName: "out of", Start: at(23), End: at(24)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRangeBounds(t *testing.T) {
+	r := report.Range{From: date(2021, 3, 15), To: date(2021, 3, 16), DayStart: 4 * time.Hour}
+
+	if got, want := r.Start(), date(2021, 3, 15).Add(4*time.Hour); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := r.End(), date(2021, 3, 17).Add(4*time.Hour); !got.Equal(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,190 @@
+package report
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+)
+
+const (
+	// FormatCSV selects comma separated values with a header line
+	FormatCSV = "csv"
+	// FormatJSON selects a single JSON array
+	FormatJSON = "json"
+	// FormatNDJSON selects one JSON object per line
+	FormatNDJSON = "ndjson"
+	// FormatHTML selects an HTML page
+	FormatHTML = "html"
+
+	// ErrUnknownFormatText is used when no exporter exists for a format
+	ErrUnknownFormatText = "unknown export format"
+)
+
+// Columns lists the fields of every exported row, in order
+var Columns = []string{"date", "app", "duration_seconds", "duration"}
+
+// Row represents the time spent in an application on a single day
+type Row struct {
+	Date     string
+	AppName  string
+	Duration time.Duration
+}
+
+// Report holds everything an exporter may render
+type Report struct {
+	Range    Range
+	Rows     []Row
+	Sessions []data.Session
+}
+
+// Exporter writes a report in a specific format
+type Exporter interface {
+	Export(w io.Writer, r Report) error
+	Extension() string
+}
+
+// GetExporter returns the exporter for the given format
+func GetExporter(format string) (Exporter, error) {
+	switch strings.ToLower(format) {
+	case FormatCSV:
+		return CSVExporter{}, nil
+	case FormatJSON:
+		return JSONExporter{}, nil
+	case FormatNDJSON:
+		return NDJSONExporter{}, nil
+	case FormatHTML:
+		return HTMLExporter{}, nil
+	}
+	return nil, fmt.Errorf("%s: %q", ErrUnknownFormatText, format)
+}
+
+// RowsFromEntries converts database entries into rows ordered by date and,
+// within a day, by descending duration
+func RowsFromEntries(entries []data.Entry) []Row {
+	rows := make([]Row, 0, len(entries))
+	for _, e := range entries {
+		rows = append(rows, Row{
+			Date:     strings.SplitN(e.ID, data.EntryIDDateSeparator, 2)[0],
+			AppName:  e.AppName,
+			Duration: e.Duration,
+		})
+	}
+
+	sortRows(rows)
+	return rows
+}
+
+// sortRows orders the rows by date, then by descending duration
+func sortRows(rows []Row) {
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].Date != rows[j].Date {
+			return rows[i].Date < rows[j].Date
+		}
+		if rows[i].Duration != rows[j].Duration {
+			return rows[i].Duration > rows[j].Duration
+		}
+		return rows[i].AppName < rows[j].AppName
+	})
+}
+
+// FormatDuration formats the duration as hh:mm:ss, rounded to the second
+func FormatDuration(duration time.Duration) string {
+	duration = duration.Round(time.Second)
+	h := duration / time.Hour
+	duration -= h * time.Hour
+	m := duration / time.Minute
+	duration -= m * time.Minute
+	s := duration / time.Second
+	return fmt.Sprintf("%02d:%02d:%02d", h, m, s)
+}
+
+// record is the serialized form of a row shared by all machine readable
+// formats
+type record struct {
+	Date            string `json:"date"`
+	App             string `json:"app"`
+	DurationSeconds int64  `json:"duration_seconds"`
+	Duration        string `json:"duration"`
+}
+
+func newRecord(row Row) record {
+	return record{
+		Date:            row.Date,
+		App:             row.AppName,
+		DurationSeconds: int64(row.Duration.Round(time.Second) / time.Second),
+		Duration:        FormatDuration(row.Duration),
+	}
+}
+
+// CSVExporter writes rows as comma separated values
+type CSVExporter struct{}
+
+// Export writes the report to w
+func (e CSVExporter) Export(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(Columns); err != nil {
+		return err
+	}
+
+	for _, row := range r.Rows {
+		rec := newRecord(row)
+		err := cw.Write([]string{rec.Date, rec.App, strconv.FormatInt(rec.DurationSeconds, 10), rec.Duration})
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// Extension returns the file extension of the format
+func (e CSVExporter) Extension() string {
+	return ".csv"
+}
+
+// JSONExporter writes rows as a JSON array
+type JSONExporter struct{}
+
+// Export writes the report to w
+func (e JSONExporter) Export(w io.Writer, r Report) error {
+	records := make([]record, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		records = append(records, newRecord(row))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(records)
+}
+
+// Extension returns the file extension of the format
+func (e JSONExporter) Extension() string {
+	return ".json"
+}
+
+// NDJSONExporter writes rows as newline delimited JSON objects
+type NDJSONExporter struct{}
+
+// Export writes the report to w
+func (e NDJSONExporter) Export(w io.Writer, r Report) error {
+	enc := json.NewEncoder(w)
+	for _, row := range r.Rows {
+		if err := enc.Encode(newRecord(row)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extension returns the file extension of the format
+func (e NDJSONExporter) Extension() string {
+	return ".ndjson"
+}
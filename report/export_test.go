@@ -0,0 +1,139 @@
+package report_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/report"
+
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var stubRows = []report.Row{
+	{Date: "2021-03-16", AppName: "Editor", Duration: 90*time.Minute + 1500*time.Millisecond},
+	{Date: "2021-03-17", AppName: "Browser, \"beta\"", Duration: 45 * time.Second},
+}
+
+var stubRecords = []map[string]interface{}{
+	{"date": "2021-03-16", "app": "Editor", "duration_seconds": float64(5402), "duration": "01:30:02"},
+	{"date": "2021-03-17", "app": "Browser, \"beta\"", "duration_seconds": float64(45), "duration": "00:00:45"},
+}
+
+func export(t *testing.T, format string) string {
+	t.Helper()
+	exporter, err := report.GetExporter(format)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	var buff bytes.Buffer
+	err = exporter.Export(&buff, report.Report{Rows: stubRows})
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	return buff.String()
+}
+
+func TestGetExporter(t *testing.T) {
+	for _, format := range []string{"csv", "JSON", "ndjson", "html"} {
+		exporter, err := report.GetExporter(format)
+		if err != nil {
+			t.Errorf("%s: no error expected, got %v", format, err)
+			continue
+		}
+		if want := "." + strings.ToLower(format); exporter.Extension() != want {
+			t.Errorf("got %q, want %q", exporter.Extension(), want)
+		}
+	}
+
+	_, err := report.GetExporter("xml")
+	if err == nil || !strings.HasPrefix(err.Error(), report.ErrUnknownFormatText) {
+		t.Errorf("got %v, want %q error", err, report.ErrUnknownFormatText)
+	}
+}
+
+func TestCSVExporter(t *testing.T) {
+	got, err := csv.NewReader(strings.NewReader(export(t, report.FormatCSV))).ReadAll()
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	want := [][]string{
+		report.Columns,
+		{"2021-03-16", "Editor", "5402", "01:30:02"},
+		{"2021-03-17", "Browser, \"beta\"", "45", "00:00:45"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestJSONExporter(t *testing.T) {
+	var got []map[string]interface{}
+	err := json.Unmarshal([]byte(export(t, report.FormatJSON)), &got)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	if !reflect.DeepEqual(got, stubRecords) {
+		t.Errorf("got %v, want %v", got, stubRecords)
+	}
+}
+
+func TestNDJSONExporter(t *testing.T) {
+	lines := strings.Split(strings.TrimSuffix(export(t, report.FormatNDJSON), "\n"), "\n")
+	if len(lines) != len(stubRecords) {
+		t.Fatalf("got %d lines, want %d", len(lines), len(stubRecords))
+	}
+
+	for i, line := range lines {
+		var got map[string]interface{}
+		err := json.Unmarshal([]byte(line), &got)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if !reflect.DeepEqual(got, stubRecords[i]) {
+			t.Errorf("got %v, want %v", got, stubRecords[i])
+		}
+	}
+}
+
+func TestHTMLExporter(t *testing.T) {
+	got := export(t, report.FormatHTML)
+
+	for _, want := range []string{"<td>Editor</td>", ">01:30:02</td>", "Browser, &#34;beta&#34;"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("%q not found in HTML output", want)
+		}
+	}
+}
+
+func TestRowsFromEntries(t *testing.T) {
+	entries := []data.Entry{
+		{ID: "2021-03-17_Editor", AppName: "Editor", Duration: time.Minute},
+		{ID: "2021-03-16_Editor", AppName: "Editor", Duration: time.Minute},
+		{ID: "2021-03-17_Browser", AppName: "Browser", Duration: time.Hour},
+	}
+
+	got := report.RowsFromEntries(entries)
+	want := []report.Row{
+		{Date: "2021-03-16", AppName: "Editor", Duration: time.Minute},
+		{Date: "2021-03-17", AppName: "Browser", Duration: time.Hour},
+		{Date: "2021-03-17", AppName: "Editor", Duration: time.Minute},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	got := report.FormatDuration(26*time.Hour + 3*time.Minute + 4600*time.Millisecond)
+	want := "26:03:05"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,94 @@
+package report
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/shldhll/hourglass/data"
+)
+
+const (
+	// GroupByApp groups the time spent by application name
+	GroupByApp = "app"
+	// GroupByProcess groups the time spent by executable
+	GroupByProcess = "process"
+	// GroupByTitle groups the time spent by window title
+	GroupByTitle = "title"
+
+	// ErrUnknownGroupText is used when sessions cannot be grouped by a field
+	ErrUnknownGroupText = "unknown group"
+)
+
+// GroupKey returns the function which extracts the value sessions are
+// grouped by. Sessions missing that value, such as idle spans, fall back to
+// their application name.
+func GroupKey(groupBy string) (func(data.Session) string, error) {
+	switch strings.ToLower(groupBy) {
+	case GroupByApp:
+		return func(s data.Session) string {
+			return s.AppName
+		}, nil
+	case GroupByProcess:
+		return func(s data.Session) string {
+			if s.Exe != "" {
+				return filepath.Base(s.Exe)
+			}
+			if len(s.Cmdline) > 0 && s.Cmdline[0] != "" {
+				return filepath.Base(s.Cmdline[0])
+			}
+			return s.AppName
+		}, nil
+	case GroupByTitle:
+		return func(s data.Session) string {
+			if s.Title != "" {
+				return s.Title
+			}
+			return s.AppName
+		}, nil
+	}
+	return nil, fmt.Errorf("%s: %q", ErrUnknownGroupText, groupBy)
+}
+
+// RowsFromSessions sums up the sessions into one row per day and group, in
+// the order of RowsFromEntries. Days start dayStart after midnight in the
+// zone of the sessions, and sessions crossing the start of a day are split
+// there. The group is stored as the AppName of a row.
+func RowsFromSessions(sessions []data.Session, groupBy string, dayStart time.Duration) ([]Row, error) {
+	key, err := GroupKey(groupBy)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []Row{}
+	index := make(map[[2]string]int)
+	add := func(day time.Time, group string, duration time.Duration) {
+		id := [2]string{day.Format(DateFormat), group}
+		i, ok := index[id]
+		if !ok {
+			i = len(rows)
+			index[id] = i
+			rows = append(rows, Row{Date: id[0], AppName: id[1]})
+		}
+		rows[i].Duration += duration
+	}
+
+	for _, session := range sessions {
+		group := key(session)
+		start := session.Start
+		for {
+			day := data.Day(start, dayStart)
+			next := data.StartOfDay(day.AddDate(0, 0, 1), dayStart)
+			if !next.Before(session.End) {
+				add(day, group, session.End.Sub(start))
+				break
+			}
+			add(day, group, next.Sub(start))
+			start = next
+		}
+	}
+
+	sortRows(rows)
+	return rows, nil
+}
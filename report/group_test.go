@@ -0,0 +1,88 @@
+package report_test
+
+import (
+	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/report"
+
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRowsFromSessions(t *testing.T) {
+	start := time.Date(2021, 3, 16, 9, 0, 0, 0, time.Local)
+	sessions := []data.Session{
+		{AppName: "Editor", Title: "a.go - Editor", Exe: "/usr/bin/editor", Start: start, End: start.Add(time.Minute)},
+		{AppName: "Editor", Title: "b.go - Editor", Cmdline: []string{"/usr/bin/editor", "b.go"}, Start: start.Add(time.Minute), End: start.Add(3 * time.Minute)},
+		{AppName: "idle", Start: start.Add(3 * time.Minute), End: start.Add(4 * time.Minute)},
+	}
+
+	tests := map[string][]report.Row{
+		report.GroupByApp: {
+			{Date: "2021-03-16", AppName: "Editor", Duration: 3 * time.Minute},
+			{Date: "2021-03-16", AppName: "idle", Duration: time.Minute},
+		},
+		report.GroupByProcess: {
+			{Date: "2021-03-16", AppName: "editor", Duration: 3 * time.Minute},
+			{Date: "2021-03-16", AppName: "idle", Duration: time.Minute},
+		},
+		report.GroupByTitle: {
+			{Date: "2021-03-16", AppName: "b.go - Editor", Duration: 2 * time.Minute},
+			{Date: "2021-03-16", AppName: "a.go - Editor", Duration: time.Minute},
+			{Date: "2021-03-16", AppName: "idle", Duration: time.Minute},
+		},
+	}
+
+	for groupBy, want := range tests {
+		t.Run(groupBy, func(t *testing.T) {
+			got, err := report.RowsFromSessions(sessions, groupBy, 0)
+			if err != nil {
+				t.Fatalf("No error expected, got %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+
+	t.Run("Day start", func(t *testing.T) {
+		late := []data.Session{{AppName: "Editor", Start: start.Add(17 * time.Hour), End: start.Add(18 * time.Hour)}}
+		got, err := report.RowsFromSessions(late, report.GroupByApp, 4*time.Hour)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		want := []report.Row{{Date: "2021-03-16", AppName: "Editor", Duration: time.Hour}}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Across midnight of the report zone", func(t *testing.T) {
+		// recorded in UTC from 21:00 to 23:30, which is 23:00 to 01:30 in
+		// the zone of the report
+		recorded := data.Session{
+			AppName: "Editor",
+			Start:   time.Date(2021, 3, 16, 21, 0, 0, 0, time.UTC),
+			End:     time.Date(2021, 3, 16, 23, 30, 0, 0, time.UTC),
+		}
+		got, err := report.RowsFromSessions([]data.Session{recorded.In(time.FixedZone("", 2*60*60))}, report.GroupByApp, 0)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		want := []report.Row{
+			{Date: "2021-03-16", AppName: "Editor", Duration: time.Hour},
+			{Date: "2021-03-17", AppName: "Editor", Duration: 90 * time.Minute},
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Unknown group", func(t *testing.T) {
+		_, err := report.RowsFromSessions(sessions, "window", 0)
+		if err == nil || !strings.HasPrefix(err.Error(), report.ErrUnknownGroupText) {
+			t.Errorf("got %v, want %q error", err, report.ErrUnknownGroupText)
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	// embed is required for the stylesheet embedded below
+	_ "embed"
+	"html/template"
+	"io"
+)
+
+//go:embed static/report.css
+var stylesheet string
+
+const htmlCode = `<!DOCTYPE html>
+<html lang="en">
+    <head>
+        <meta charset="utf-8">
+        <meta name="viewport" content="width=device-width, initial-scale=1">
+        <title>Tracking Data</title>
+        <style>{{.Stylesheet}}</style>
+    </head>
+    <body>
+        <main>
+        <h1>hourglass</h1>
+        {{if not .Range.From.IsZero}}<p class="range">{{.Range.From.Format "2006-01-02"}} &ndash; {{.Range.To.Format "2006-01-02"}}</p>{{end}}
+        {{with .Rows}}
+        <h2>Applications</h2>
+        {{$.AppChart}}
+        <h2>Days</h2>
+        {{$.DayChart}}
+        {{end}}
+        {{with .Sessions}}
+        <h2>Hours</h2>
+        {{$.Heatmap}}
+        {{end}}
+        {{with .Rows}}
+        <h2>Details</h2>
+        <table>
+            <thead>
+              <tr>
+                <th scope="col">Date</th>
+                <th scope="col">Application</th>
+                <th scope="col">Duration</th>
+              </tr>
+            </thead>
+            <tbody>
+              {{range .}}
+              <tr>
+                <th scope="row">{{.Date}}</th>
+                <td>{{.AppName}}</td>
+                <td class="duration">{{formatDuration .Duration}}</td>
+              </tr>
+              {{end}}
+            </tbody>
+          </table>
+          {{end}}
+        </main>
+    </body>
+</html>`
+
+var htmlTemplate = template.Must(template.New("data").Funcs(template.FuncMap{
+	"formatDuration": FormatDuration,
+}).Parse(htmlCode))
+
+// HTMLExporter writes a self-contained HTML page with charts and a table of
+// all rows. The page does not reference any external resources.
+type HTMLExporter struct{}
+
+// Export writes the report to w
+func (e HTMLExporter) Export(w io.Writer, r Report) error {
+	return htmlTemplate.Execute(w, struct {
+		Report
+		Stylesheet template.CSS
+		AppChart   template.HTML
+		DayChart   template.HTML
+		Heatmap    template.HTML
+	}{
+		Report:     r,
+		Stylesheet: template.CSS(stylesheet),
+		AppChart:   AppBarChart(r.Rows),
+		DayChart:   DayStackedChart(r.Rows, r.Range),
+		Heatmap:    HourHeatmap(r.Sessions, r.Range),
+	})
+}
+
+// Extension returns the file extension of the format
+func (e HTMLExporter) Extension() string {
+	return ".html"
+}
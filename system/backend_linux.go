@@ -0,0 +1,82 @@
+package system
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+const (
+	// BackendAuto selects the backend matching the graphical session
+	BackendAuto = "auto"
+	// BackendX11 talks to the X server directly
+	BackendX11 = "x11"
+	// BackendXprop reads the X server through the xprop command
+	BackendXprop = "xprop"
+	// BackendSway talks to sway over its IPC socket
+	BackendSway = "sway"
+	// BackendI3 talks to i3 over its IPC socket
+	BackendI3 = "i3"
+	// BackendHyprland talks to Hyprland over its IPC sockets
+	BackendHyprland = "hyprland"
+
+	// ErrUnknownBackendText is used when no backend exists for a name
+	ErrUnknownBackendText = "unknown backend"
+)
+
+// Backends lists the names accepted by Open
+var Backends = []string{BackendAuto, BackendX11, BackendXprop, BackendSway, BackendI3, BackendHyprland}
+
+// Open returns the backend of the given name. BackendAuto, or an empty name,
+// selects it through FromEnv.
+func Open(backend string) (OS, error) {
+	var o OS
+	var err error
+
+	switch strings.ToLower(backend) {
+	case "", BackendAuto:
+		return FromEnv()
+	case BackendX11:
+		o, err = NewX11("")
+	case BackendXprop:
+		o = Current{}
+	case BackendSway:
+		o, err = NewSway("")
+	case BackendI3:
+		o, err = NewI3("")
+	case BackendHyprland:
+		o, err = NewHyprland("")
+	default:
+		err = fmt.Errorf("%s: %q", ErrUnknownBackendText, backend)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// FromEnv returns the backend matching the graphical session the process
+// runs in: Hyprland, sway or i3 if their environment variables are set,
+// otherwise a direct connection to the X server. If that backend cannot be
+// used, the xprop backend is returned together with the error.
+func FromEnv() (OS, error) {
+	o, err := Open(envBackend())
+	if err != nil {
+		return Current{}, err
+	}
+	return o, nil
+}
+
+// envBackend returns the name of the backend matching the environment
+func envBackend() string {
+	switch {
+	case os.Getenv(HyprlandSignatureEnv) != "":
+		return BackendHyprland
+	case os.Getenv(SwaySocketEnv) != "":
+		return BackendSway
+	case os.Getenv(I3SocketEnv) != "":
+		return BackendI3
+	}
+	return BackendX11
+}
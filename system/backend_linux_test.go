@@ -0,0 +1,30 @@
+package system_test
+
+import (
+	"github.com/shldhll/hourglass/system"
+
+	"strings"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	t.Run("xprop", func(t *testing.T) {
+		o, err := system.Open(system.BackendXprop)
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if _, ok := o.(system.Current); !ok {
+			t.Errorf("got %T, want system.Current", o)
+		}
+	})
+
+	t.Run("Unknown backend", func(t *testing.T) {
+		o, err := system.Open("wayland")
+		if err == nil || !strings.HasPrefix(err.Error(), system.ErrUnknownBackendText) {
+			t.Errorf("got %v, want %q error", err, system.ErrUnknownBackendText)
+		}
+		if o != nil {
+			t.Errorf("got %v, want nil", o)
+		}
+	})
+}
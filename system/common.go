@@ -1,18 +1,60 @@
 package system
 
-import "time"
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
 
 // OS represents an operating system
 type OS interface {
-	GetApplicationName() string
+	Sample() (Sample, error)
+	IdleTime() (time.Duration, error)
 	Now() time.Time
 	Log(string)
 }
 
+// Watcher is implemented by operating systems which report changes of the
+// focused window, or of its title, as they happen. The channel is closed
+// once no more changes will be reported.
+type Watcher interface {
+	FocusChanges() <-chan struct{}
+}
+
+// Sample describes the focused window and the process owning it
+type Sample struct {
+	Title   string
+	Class   string
+	PID     int
+	Exe     string
+	Cmdline []string
+}
+
+// AppName returns the name of the application the sample belongs to, which
+// entries are keyed by: the last segment of the window title, as hourglass
+// has always named applications, or, for windows without a title, the
+// window class or the name of the executable
+func (s Sample) AppName() string {
+	if s.Title != "" {
+		titleSplitRes := strings.Split(s.Title, " - ")
+		return titleSplitRes[len(titleSplitRes)-1]
+	}
+	if s.Class != "" {
+		return s.Class
+	}
+	if s.Exe != "" {
+		return filepath.Base(s.Exe)
+	}
+	return ""
+}
+
 // Config represents various configurations
 type Config interface {
 	GetCooldownTime() time.Duration
 	GetMinUsageTime() time.Duration
+	GetIdleThreshold() time.Duration
+	GetDayStart() time.Duration
 	LoopCheck() bool
 	LoopNext()
 }
@@ -21,6 +63,8 @@ type Config interface {
 type Cfg struct {
 	cooldownTime  time.Duration
 	minUsageTime  time.Duration
+	idleThreshold time.Duration
+	dayStart      time.Duration
 	loopCheckBool bool
 }
 
@@ -34,22 +78,92 @@ func (c Cfg) GetMinUsageTime() time.Duration {
 	return c.minUsageTime
 }
 
+// GetIdleThreshold returns the duration without user input after which
+// the user is considered idle. A zero threshold disables idle detection.
+func (c Cfg) GetIdleThreshold() time.Duration {
+	return c.idleThreshold
+}
+
+// GetDayStart returns the time after midnight at which a new day starts.
+// Time before it is credited to the previous day.
+func (c Cfg) GetDayStart() time.Duration {
+	return c.dayStart
+}
+
 // LoopCheck replicates a custom loop condition check
 func (c Cfg) LoopCheck() bool {
 	return c.loopCheckBool
 }
 
-// LoopNext is called before each loop
-func (c Cfg) LoopNext() {
-	time.Sleep(c.GetCooldownTime())
-}
+// LoopNext is called at the end of each loop. The tracker itself waits for
+// the cooldown time between loops.
+func (c Cfg) LoopNext() {}
 
 // GetConfig returns a config struct with the given properties
-func GetConfig(cooldown, minUsage time.Duration) Config {
+func GetConfig(cooldown, minUsage, idleThreshold, dayStart time.Duration) Config {
 	cfg := Cfg{
 		cooldownTime:  cooldown,
 		minUsageTime:  minUsage,
+		idleThreshold: idleThreshold,
+		dayStart:      dayStart,
 		loopCheckBool: true,
 	}
 	return cfg
 }
+
+// SharedConfig implements Config by delegating to a config which can be
+// replaced while it is in use
+type SharedConfig struct {
+	mu  sync.RWMutex
+	cfg Config
+}
+
+// NewSharedConfig returns a shared config delegating to cfg
+func NewSharedConfig(cfg Config) *SharedConfig {
+	return &SharedConfig{cfg: cfg}
+}
+
+// Set replaces the config delegated to
+func (s *SharedConfig) Set(cfg Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cfg = cfg
+}
+
+func (s *SharedConfig) get() Config {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.cfg
+}
+
+// GetCooldownTime returns cooldown duration
+func (s *SharedConfig) GetCooldownTime() time.Duration {
+	return s.get().GetCooldownTime()
+}
+
+// GetMinUsageTime returns minimum time duration
+func (s *SharedConfig) GetMinUsageTime() time.Duration {
+	return s.get().GetMinUsageTime()
+}
+
+// GetIdleThreshold returns the idle threshold
+func (s *SharedConfig) GetIdleThreshold() time.Duration {
+	return s.get().GetIdleThreshold()
+}
+
+// GetDayStart returns the time after midnight at which a new day starts
+func (s *SharedConfig) GetDayStart() time.Duration {
+	return s.get().GetDayStart()
+}
+
+// LoopCheck replicates a custom loop condition check
+func (s *SharedConfig) LoopCheck() bool {
+	return s.get().LoopCheck()
+}
+
+// LoopNext is called at the end of each loop
+func (s *SharedConfig) LoopNext() {
+	s.get().LoopNext()
+}
@@ -0,0 +1,166 @@
+package system
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// ipcTimeout limits the time a request to a compositor may take
+const ipcTimeout = 2 * time.Second
+
+var (
+	// ErrCompositorClosed is returned by a Compositor after Close was called
+	ErrCompositorClosed = errors.New("compositor connection closed")
+	// ErrIdleTimeUnsupported is returned by backends which cannot tell the
+	// time elapsed since the last user input
+	ErrIdleTimeUnsupported = errors.New("idle time is not supported by this backend")
+)
+
+// compositorIPC is the client of the IPC interface of a window manager or
+// compositor
+type compositorIPC interface {
+	// focused returns the focused window
+	focused() (Sample, error)
+	// subscribe opens a stream of the events of the compositor
+	subscribe() (compositorEvents, error)
+}
+
+// compositorEvents is a stream of events of a compositor
+type compositorEvents interface {
+	// next waits for the next event and reports whether the focused window
+	// or its title may have changed
+	next() (bool, error)
+	Close() error
+}
+
+// Compositor reads the focused window over the IPC interface of a window
+// manager or compositor. It subscribes to its events to report focus changes
+// and subscribes again on the next call after the connection was lost. It is
+// safe for concurrent use.
+type Compositor struct {
+	ipc     compositorIPC
+	changes chan struct{}
+
+	mu     sync.Mutex
+	events compositorEvents
+	closed bool
+}
+
+// newCompositor subscribes to the events of the compositor behind ipc
+func newCompositor(ipc compositorIPC) (*Compositor, error) {
+	c := &Compositor{ipc: ipc, changes: make(chan struct{}, 1)}
+	if err := c.subscribe(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Sample returns information about the current forground window
+func (c *Compositor) Sample() (Sample, error) {
+	if err := c.subscribe(); err != nil {
+		return Sample{}, err
+	}
+
+	sample, err := c.ipc.focused()
+	if err != nil {
+		return sample, err
+	}
+	sample.Exe, sample.Cmdline = processInfo(sample.PID)
+	return sample, nil
+}
+
+// IdleTime returns ErrIdleTimeUnsupported, compositors do not report the
+// time elapsed since the last user input over IPC
+func (c *Compositor) IdleTime() (time.Duration, error) {
+	return 0, ErrIdleTimeUnsupported
+}
+
+// Now returns current time
+func (c *Compositor) Now() time.Time {
+	return time.Now()
+}
+
+// Log is used for system specific logging
+func (c *Compositor) Log(msg string) {
+	log.Print(msg)
+}
+
+// FocusChanges returns a channel receiving a value whenever the focused
+// window or its title changes. It is closed by Close.
+func (c *Compositor) FocusChanges() <-chan struct{} {
+	return c.changes
+}
+
+// Close closes the connection to the compositor
+func (c *Compositor) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.closed {
+		close(c.changes)
+	}
+	c.closed = true
+	if c.events != nil {
+		c.events.Close()
+		c.events = nil
+	}
+	return nil
+}
+
+// subscribe subscribes to the events of the compositor unless it is
+// subscribed already
+func (c *Compositor) subscribe() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return ErrCompositorClosed
+	}
+	if c.events != nil {
+		return nil
+	}
+
+	events, err := c.ipc.subscribe()
+	if err != nil {
+		return err
+	}
+	c.events = events
+	go c.watch(events)
+	return nil
+}
+
+// watch reports focus changes until the connection is lost
+func (c *Compositor) watch(events compositorEvents) {
+	for {
+		changed, err := events.next()
+		if err != nil {
+			break
+		}
+		if changed {
+			c.notify()
+		}
+	}
+
+	c.mu.Lock()
+	if c.events == events {
+		c.events = nil
+	}
+	c.mu.Unlock()
+	events.Close()
+}
+
+// notify reports a change without waiting for it to be received
+func (c *Compositor) notify() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return
+	}
+	select {
+	case c.changes <- struct{}{}:
+	default:
+	}
+}
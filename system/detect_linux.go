@@ -0,0 +1,74 @@
+package system
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+)
+
+// ErrNoDisplay is returned when no X server is known
+var ErrNoDisplay = errors.New("DISPLAY is not set")
+
+// Probe is the result of checking whether a backend can be used. Err tells
+// why it cannot.
+type Probe struct {
+	Backend string
+	Err     error
+}
+
+// Available reports whether the backend can be used
+func (p Probe) Available() bool {
+	return p.Err == nil
+}
+
+// Detection describes the backends available to the process
+type Detection struct {
+	// Probes holds a probe per backend, in the order of Backends
+	Probes []Probe
+	// Selected is the backend Open uses for BackendAuto
+	Selected string
+}
+
+// Detect probes every backend by connecting to it
+func Detect() Detection {
+	var detection Detection
+	available := make(map[string]bool)
+
+	for _, backend := range Backends {
+		if backend == BackendAuto {
+			continue
+		}
+
+		probe := Probe{Backend: backend}
+		if backend == BackendXprop {
+			probe.Err = probeXprop()
+		} else {
+			var o OS
+			o, probe.Err = Open(backend)
+			if c, ok := o.(io.Closer); ok {
+				c.Close()
+			}
+		}
+
+		available[backend] = probe.Available()
+		detection.Probes = append(detection.Probes, probe)
+	}
+
+	detection.Selected = envBackend()
+	if !available[detection.Selected] {
+		detection.Selected = BackendXprop
+	}
+	return detection
+}
+
+// probeXprop checks that xprop is installed and has an X server to ask
+func probeXprop() error {
+	if _, err := exec.LookPath("xprop"); err != nil {
+		return err
+	}
+	if os.Getenv("DISPLAY") == "" {
+		return ErrNoDisplay
+	}
+	return nil
+}
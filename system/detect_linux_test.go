@@ -0,0 +1,64 @@
+package system_test
+
+import (
+	"github.com/shldhll/hourglass/system"
+
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setenv sets the environment variable for the duration of the test
+func setenv(t *testing.T, key, value string) {
+	t.Helper()
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func probe(detection system.Detection, backend string) system.Probe {
+	for _, p := range detection.Probes {
+		if p.Backend == backend {
+			return p
+		}
+	}
+	return system.Probe{}
+}
+
+func TestDetect(t *testing.T) {
+	setenv(t, system.HyprlandSignatureEnv, "")
+
+	t.Run("Sway running", func(t *testing.T) {
+		ipc := newStubI3ipc(t, stubTree("foot"))
+		setenv(t, system.SwaySocketEnv, ipc.path)
+
+		detection := system.Detect()
+		if detection.Selected != system.BackendSway {
+			t.Errorf("got %q, want %q", detection.Selected, system.BackendSway)
+		}
+		if p := probe(detection, system.BackendSway); !p.Available() {
+			t.Errorf("got %v, want sway available", p.Err)
+		}
+		if p := probe(detection, system.BackendHyprland); p.Err != system.ErrNoHyprlandSignature {
+			t.Errorf("got %v, want %v", p.Err, system.ErrNoHyprlandSignature)
+		}
+	})
+
+	t.Run("Sway not reachable", func(t *testing.T) {
+		setenv(t, system.SwaySocketEnv, filepath.Join(t.TempDir(), "missing.sock"))
+
+		detection := system.Detect()
+		if detection.Selected != system.BackendXprop {
+			t.Errorf("got %q, want %q", detection.Selected, system.BackendXprop)
+		}
+		if p := probe(detection, system.BackendSway); p.Available() {
+			t.Error("got sway available, want an error")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+package system
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// HyprlandSignatureEnv holds the instance signature of the running Hyprland
+	HyprlandSignatureEnv = "HYPRLAND_INSTANCE_SIGNATURE"
+
+	hyprlandRequestSocket = ".socket.sock"
+	hyprlandEventSocket   = ".socket2.sock"
+	hyprlandEventSep      = ">>"
+)
+
+// ErrNoHyprlandSignature is returned when the Hyprland instance is not known
+var ErrNoHyprlandSignature = errors.New(HyprlandSignatureEnv + " is not set")
+
+// NewHyprland connects to the sockets of the Hyprland instance in dir. An
+// empty dir uses the instance named by HYPRLAND_INSTANCE_SIGNATURE.
+func NewHyprland(dir string) (*Compositor, error) {
+	if dir == "" {
+		signature := os.Getenv(HyprlandSignatureEnv)
+		if signature == "" {
+			return nil, ErrNoHyprlandSignature
+		}
+		dir = hyprlandDir(signature)
+	}
+	return newCompositor(hyprlandClient{dir: dir})
+}
+
+// hyprlandDir returns the directory holding the sockets of the instance.
+// Hyprland moved them from /tmp/hypr to $XDG_RUNTIME_DIR/hypr.
+func hyprlandDir(signature string) string {
+	dirs := []string{filepath.Join("/tmp/hypr", signature)}
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		dirs = append([]string{filepath.Join(runtime, "hypr", signature)}, dirs...)
+	}
+
+	for _, dir := range dirs {
+		if _, err := os.Stat(filepath.Join(dir, hyprlandRequestSocket)); err == nil {
+			return dir
+		}
+	}
+	return dirs[0]
+}
+
+// hyprlandClient talks to Hyprland over its request and event sockets
+type hyprlandClient struct {
+	dir string
+}
+
+// focused returns the active window
+func (c hyprlandClient) focused() (Sample, error) {
+	conn, err := net.DialTimeout("unix", filepath.Join(c.dir, hyprlandRequestSocket), ipcTimeout)
+	if err != nil {
+		return Sample{}, err
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(ipcTimeout)); err != nil {
+		return Sample{}, err
+	}
+	if _, err = conn.Write([]byte("j/activewindow")); err != nil {
+		return Sample{}, err
+	}
+	reply, err := ioutil.ReadAll(conn)
+	if err != nil {
+		return Sample{}, err
+	}
+
+	var window struct {
+		Address string `json:"address"`
+		Title   string `json:"title"`
+		Class   string `json:"class"`
+		PID     int    `json:"pid"`
+	}
+	if err = json.Unmarshal(reply, &window); err != nil {
+		return Sample{}, err
+	}
+	if window.Address == "" {
+		return Sample{}, ErrNoActiveWindow
+	}
+	return Sample{Title: window.Title, Class: window.Class, PID: window.PID}, nil
+}
+
+// subscribe connects to the event socket
+func (c hyprlandClient) subscribe() (compositorEvents, error) {
+	conn, err := net.DialTimeout("unix", filepath.Join(c.dir, hyprlandEventSocket), ipcTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return hyprlandEvents{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// hyprlandEvents is a connection to the event socket, which sends one
+// EVENT>>DATA line per event
+type hyprlandEvents struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+// next reads the next event, a window gaining focus, being renamed or closed
+// changes the focused window
+func (e hyprlandEvents) next() (bool, error) {
+	line, err := e.reader.ReadString('\n')
+	if err != nil {
+		return false, err
+	}
+
+	switch strings.SplitN(line, hyprlandEventSep, 2)[0] {
+	case "activewindow", "activewindowv2", "windowtitle", "windowtitlev2", "closewindow":
+		return true, nil
+	}
+	return false, nil
+}
@@ -0,0 +1,163 @@
+package system_test
+
+import (
+	"github.com/shldhll/hourglass/system"
+
+	"bufio"
+	"net"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// stubHyprland serves the request and event sockets of Hyprland
+type stubHyprland struct {
+	dir string
+
+	mu          sync.Mutex
+	window      string
+	subscribers []net.Conn
+}
+
+func newStubHyprland(t *testing.T, window string) *stubHyprland {
+	t.Helper()
+	s := &stubHyprland{dir: t.TempDir(), window: window}
+
+	requests, err := net.Listen("unix", filepath.Join(s.dir, ".socket.sock"))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	events, err := net.Listen("unix", filepath.Join(s.dir, ".socket2.sock"))
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	t.Cleanup(func() {
+		requests.Close()
+		events.Close()
+		s.disconnect()
+	})
+
+	go func() {
+		for {
+			conn, err := requests.Accept()
+			if err != nil {
+				return
+			}
+			line, _ := bufio.NewReader(conn).Peek(len("j/activewindow"))
+			s.mu.Lock()
+			if string(line) == "j/activewindow" {
+				conn.Write([]byte(s.window))
+			}
+			s.mu.Unlock()
+			conn.Close()
+		}
+	}()
+	go func() {
+		for {
+			conn, err := events.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.subscribers = append(s.subscribers, conn)
+			s.mu.Unlock()
+		}
+	}()
+	return s
+}
+
+func (s *stubHyprland) setWindow(window string) {
+	s.mu.Lock()
+	s.window = window
+	s.mu.Unlock()
+}
+
+// event sends the event line to every subscriber, waiting for the first
+// one to connect
+func (s *stubHyprland) event(line string) {
+	for i := 0; i < 100 && s.subscriberCount() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.subscribers {
+		conn.Write([]byte(line + "\n"))
+	}
+}
+
+func (s *stubHyprland) subscriberCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.subscribers)
+}
+
+// disconnect closes the connections of every subscriber
+func (s *stubHyprland) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.subscribers {
+		conn.Close()
+	}
+	s.subscribers = nil
+}
+
+const stubHyprlandWindow = `{"address":"0x55d0","mapped":true,"at":[0,0],"size":[800,600],"workspace":{"id":1,"name":"1"},
+	"class":"kitty","title":"~ - kitty","initialClass":"kitty","initialTitle":"kitty","pid":4242}`
+
+func TestHyprland(t *testing.T) {
+	hypr := newStubHyprland(t, stubHyprlandWindow)
+	h, err := system.NewHyprland(hypr.dir)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	defer h.Close()
+
+	t.Run("Active window", func(t *testing.T) {
+		got, err := h.Sample()
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if got.Title != "~ - kitty" || got.Class != "kitty" || got.PID != 4242 {
+			t.Errorf("got %v, want the kitty window", got)
+		}
+	})
+
+	t.Run("No active window", func(t *testing.T) {
+		hypr.setWindow("{}")
+		if _, err := h.Sample(); err != system.ErrNoActiveWindow {
+			t.Errorf("got %v, want %v", err, system.ErrNoActiveWindow)
+		}
+	})
+
+	t.Run("Ignored event", func(t *testing.T) {
+		hypr.event("workspace>>2")
+
+		select {
+		case <-h.FocusChanges():
+			t.Error("got a change for a workspace event")
+		case <-time.After(100 * time.Millisecond):
+		}
+	})
+
+	t.Run("Active window event", func(t *testing.T) {
+		hypr.event("activewindow>>kitty,~ - kitty")
+		waitChange(t, h.FocusChanges())
+	})
+
+	t.Run("Title event", func(t *testing.T) {
+		hypr.event("windowtitle>>55d0")
+		waitChange(t, h.FocusChanges())
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		h.Close()
+		if _, err := h.Sample(); err != system.ErrCompositorClosed {
+			t.Errorf("got %v, want %v", err, system.ErrCompositorClosed)
+		}
+	})
+}
@@ -0,0 +1,259 @@
+package system
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Message and event types of the i3 IPC protocol, which sway speaks as well
+const (
+	i3ipcSubscribe = 2
+	i3ipcGetTree   = 4
+
+	i3ipcEventMask   = 1 << 31
+	i3ipcWindowEvent = i3ipcEventMask | 3
+)
+
+// i3ipcMagic starts every message of the i3 IPC protocol
+const i3ipcMagic = "i3-ipc"
+
+// maxI3ipcPayload is the size of the largest message accepted
+const maxI3ipcPayload = 64 << 20
+
+// ErrI3ipcMagic is returned when a message does not start with i3ipcMagic
+var ErrI3ipcMagic = errors.New("invalid i3 IPC message")
+
+const (
+	// SwaySocketEnv holds the path of the IPC socket of the running sway
+	SwaySocketEnv = "SWAYSOCK"
+	// I3SocketEnv holds the path of the IPC socket of the running i3
+	I3SocketEnv = "I3SOCK"
+)
+
+var (
+	// ErrNoSwaySocket is returned when the sway IPC socket is not known
+	ErrNoSwaySocket = errors.New(SwaySocketEnv + " is not set")
+	// ErrNoI3Socket is returned when the i3 IPC socket is not known
+	ErrNoI3Socket = errors.New(I3SocketEnv + " is not set and i3 --get-socketpath failed")
+)
+
+// NewSway connects to the sway IPC socket at path. An empty path uses the
+// SWAYSOCK environment variable.
+func NewSway(path string) (*Compositor, error) {
+	if path == "" {
+		path = os.Getenv(SwaySocketEnv)
+	}
+	if path == "" {
+		return nil, ErrNoSwaySocket
+	}
+	return newCompositor(i3ipcClient{socket: path})
+}
+
+// NewI3 connects to the i3 IPC socket at path. An empty path uses the I3SOCK
+// environment variable or asks i3 for the path of its socket.
+func NewI3(path string) (*Compositor, error) {
+	if path == "" {
+		path = os.Getenv(I3SocketEnv)
+	}
+	if path == "" {
+		out, err := exec.Command("i3", "--get-socketpath").Output()
+		if err != nil {
+			return nil, ErrNoI3Socket
+		}
+		path = strings.TrimSpace(string(out))
+	}
+	return newCompositor(i3ipcClient{socket: path})
+}
+
+// i3ipcClient talks to i3 and sway, which share the same IPC protocol
+type i3ipcClient struct {
+	socket string
+}
+
+// focused returns the focused window of the layout tree
+func (c i3ipcClient) focused() (Sample, error) {
+	var tree i3Node
+	if err := i3ipcRequest(c.socket, i3ipcGetTree, nil, &tree); err != nil {
+		return Sample{}, err
+	}
+
+	node := tree.focused()
+	if node == nil || (node.Type != "con" && node.Type != "floating_con") {
+		return Sample{}, ErrNoActiveWindow
+	}
+	return node.sample(), nil
+}
+
+// subscribe subscribes to window events
+func (c i3ipcClient) subscribe() (compositorEvents, error) {
+	conn, err := net.DialTimeout("unix", c.socket, ipcTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	var reply struct {
+		Success bool `json:"success"`
+	}
+	conn.SetDeadline(time.Now().Add(ipcTimeout))
+	err = writeI3ipc(conn, i3ipcSubscribe, []byte(`["window"]`))
+	if err == nil {
+		var payload []byte
+		_, payload, err = readI3ipc(conn)
+		if err == nil {
+			err = json.Unmarshal(payload, &reply)
+		}
+	}
+	if err == nil && !reply.Success {
+		err = errors.New("subscription to window events refused")
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+
+	return i3ipcEvents{conn}, nil
+}
+
+// i3ipcEvents is a connection subscribed to window events
+type i3ipcEvents struct {
+	net.Conn
+}
+
+// next reads the next event, a window gaining focus, being renamed or closed
+// changes the focused window
+func (e i3ipcEvents) next() (bool, error) {
+	msgType, payload, err := readI3ipc(e.Conn)
+	if err != nil || msgType != i3ipcWindowEvent {
+		return false, err
+	}
+
+	var event struct {
+		Change string `json:"change"`
+	}
+	if json.Unmarshal(payload, &event) != nil {
+		return false, nil
+	}
+	switch event.Change {
+	case "focus", "title", "close":
+		return true, nil
+	}
+	return false, nil
+}
+
+// writeI3ipc writes a message of the given type
+func writeI3ipc(w io.Writer, msgType uint32, payload []byte) error {
+	msg := make([]byte, len(i3ipcMagic)+8+len(payload))
+	copy(msg, i3ipcMagic)
+	binary.LittleEndian.PutUint32(msg[len(i3ipcMagic):], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(msg[len(i3ipcMagic)+4:], msgType)
+	copy(msg[len(i3ipcMagic)+8:], payload)
+
+	_, err := w.Write(msg)
+	return err
+}
+
+// readI3ipc reads a single message and returns its type and payload
+func readI3ipc(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, len(i3ipcMagic)+8)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	if string(header[:len(i3ipcMagic)]) != i3ipcMagic {
+		return 0, nil, ErrI3ipcMagic
+	}
+
+	length := binary.LittleEndian.Uint32(header[len(i3ipcMagic):])
+	msgType := binary.LittleEndian.Uint32(header[len(i3ipcMagic)+4:])
+	if length > maxI3ipcPayload {
+		return 0, nil, fmt.Errorf("%w: payload of %d bytes", ErrI3ipcMagic, length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	return msgType, payload, nil
+}
+
+// i3ipcRequest sends a message over a new connection to the socket at path
+// and decodes the reply into v
+func i3ipcRequest(path string, msgType uint32, payload []byte, v interface{}) error {
+	conn, err := net.DialTimeout("unix", path, ipcTimeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = conn.SetDeadline(time.Now().Add(ipcTimeout)); err != nil {
+		return err
+	}
+	if err = writeI3ipc(conn, msgType, payload); err != nil {
+		return err
+	}
+
+	replyType, reply, err := readI3ipc(conn)
+	if err != nil {
+		return err
+	}
+	if replyType != msgType {
+		return fmt.Errorf("%w: reply of type %d to message of type %d", ErrI3ipcMagic, replyType, msgType)
+	}
+	return json.Unmarshal(reply, v)
+}
+
+// i3Node is a node of the layout tree returned by GET_TREE
+type i3Node struct {
+	Name             string `json:"name"`
+	Type             string `json:"type"`
+	Focused          bool   `json:"focused"`
+	PID              int    `json:"pid"`
+	AppID            string `json:"app_id"`
+	Window           int    `json:"window"`
+	WindowProperties struct {
+		Class    string `json:"class"`
+		Instance string `json:"instance"`
+		Title    string `json:"title"`
+	} `json:"window_properties"`
+	Nodes         []i3Node `json:"nodes"`
+	FloatingNodes []i3Node `json:"floating_nodes"`
+}
+
+// focused returns the focused node of the tree, if there is one
+func (n *i3Node) focused() *i3Node {
+	if n.Focused {
+		return n
+	}
+	for i := range n.Nodes {
+		if f := n.Nodes[i].focused(); f != nil {
+			return f
+		}
+	}
+	for i := range n.FloatingNodes {
+		if f := n.FloatingNodes[i].focused(); f != nil {
+			return f
+		}
+	}
+	return nil
+}
+
+// sample describes the window of the node. Native Wayland windows are named
+// after their app_id, X11 windows after their WM_CLASS.
+func (n *i3Node) sample() Sample {
+	sample := Sample{Title: n.Name, Class: n.AppID, PID: n.PID}
+	if sample.Class == "" {
+		sample.Class = n.WindowProperties.Class
+	}
+	if sample.Title == "" {
+		sample.Title = n.WindowProperties.Title
+	}
+	return sample
+}
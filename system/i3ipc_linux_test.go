@@ -0,0 +1,235 @@
+package system_test
+
+import (
+	"github.com/shldhll/hourglass/system"
+
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	stubIPCGetTree   = 4
+	stubIPCSubscribe = 2
+	stubWindowEvent  = 1<<31 | 3
+)
+
+// stubI3ipc serves the i3 IPC protocol on a unix socket
+type stubI3ipc struct {
+	path     string
+	listener net.Listener
+
+	mu          sync.Mutex
+	tree        string
+	subscribers []net.Conn
+}
+
+func newStubI3ipc(t *testing.T, tree string) *stubI3ipc {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "ipc.sock")
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	s := &stubI3ipc{path: path, listener: listener, tree: tree}
+	t.Cleanup(func() {
+		listener.Close()
+		s.disconnect()
+	})
+	go s.serve()
+	return s
+}
+
+func (s *stubI3ipc) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveConn(conn)
+	}
+}
+
+func (s *stubI3ipc) serveConn(conn net.Conn) {
+	for {
+		msgType, _, err := readStubI3ipc(conn)
+		if err != nil {
+			conn.Close()
+			return
+		}
+
+		switch msgType {
+		case stubIPCGetTree:
+			s.mu.Lock()
+			tree := s.tree
+			s.mu.Unlock()
+			writeStubI3ipc(conn, msgType, tree)
+		case stubIPCSubscribe:
+			s.mu.Lock()
+			s.subscribers = append(s.subscribers, conn)
+			writeStubI3ipc(conn, msgType, `{"success":true}`)
+			s.mu.Unlock()
+		}
+	}
+}
+
+func (s *stubI3ipc) setTree(tree string) {
+	s.mu.Lock()
+	s.tree = tree
+	s.mu.Unlock()
+}
+
+// event sends a window event to every subscriber
+func (s *stubI3ipc) event(change string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.subscribers {
+		writeStubI3ipc(conn, stubWindowEvent, `{"change":"`+change+`","container":{}}`)
+	}
+}
+
+// disconnect closes the connections of every subscriber
+func (s *stubI3ipc) disconnect() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.subscribers {
+		conn.Close()
+	}
+	s.subscribers = nil
+}
+
+func writeStubI3ipc(w io.Writer, msgType uint32, payload string) {
+	header := make([]byte, 14)
+	copy(header, "i3-ipc")
+	binary.LittleEndian.PutUint32(header[6:], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(header[10:], msgType)
+	w.Write(append(header, payload...))
+}
+
+func readStubI3ipc(r io.Reader) (uint32, []byte, error) {
+	header := make([]byte, 14)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, binary.LittleEndian.Uint32(header[6:]))
+	_, err := io.ReadFull(r, payload)
+	return binary.LittleEndian.Uint32(header[10:]), payload, err
+}
+
+// stubTree returns a layout tree holding a terminal and an X11 browser, with
+// the window of the given name focused
+func stubTree(focused string) string {
+	pid := strconv.Itoa(os.Getpid())
+	return `{"type":"root","focused":false,"nodes":[{"type":"output","nodes":[
+		{"type":"workspace","name":"1","focused":` + strconv.FormatBool(focused == "workspace") + `,"nodes":[
+			{"type":"con","name":"~ - foot","app_id":"foot","pid":` + pid + `,"focused":` + strconv.FormatBool(focused == "foot") + `,"nodes":[]}
+		],"floating_nodes":[
+			{"type":"floating_con","name":"News - Browser","app_id":null,"pid":4343,"focused":` + strconv.FormatBool(focused == "browser") + `,
+			 "window_properties":{"class":"Browser","instance":"browser","title":"News - Browser"},"nodes":[]}
+		]}
+	]}]}`
+}
+
+func TestSway(t *testing.T) {
+	testI3ipc(t, system.NewSway)
+}
+
+func TestI3(t *testing.T) {
+	testI3ipc(t, system.NewI3)
+}
+
+// testI3ipc tests a backend talking the i3 IPC protocol
+func testI3ipc(t *testing.T, open func(path string) (*system.Compositor, error)) {
+	ipc := newStubI3ipc(t, stubTree("foot"))
+	s, err := open(ipc.path)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	defer s.Close()
+
+	t.Run("Focused window", func(t *testing.T) {
+		got, err := s.Sample()
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		exe, _ := os.Executable()
+		if got.Title != "~ - foot" || got.Class != "foot" || got.PID != os.Getpid() || got.Exe != exe {
+			t.Errorf("got %v, want the foot window", got)
+		}
+	})
+
+	t.Run("X11 window", func(t *testing.T) {
+		ipc.setTree(stubTree("browser"))
+		got, err := s.Sample()
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if got.Title != "News - Browser" || got.Class != "Browser" || got.PID != 4343 {
+			t.Errorf("got %v, want the browser window", got)
+		}
+	})
+
+	t.Run("Empty workspace", func(t *testing.T) {
+		ipc.setTree(stubTree("workspace"))
+		if _, err := s.Sample(); err != system.ErrNoActiveWindow {
+			t.Errorf("got %v, want %v", err, system.ErrNoActiveWindow)
+		}
+	})
+
+	t.Run("Window event", func(t *testing.T) {
+		ipc.event("focus")
+		waitChange(t, s.FocusChanges())
+	})
+
+	t.Run("Subscribed again", func(t *testing.T) {
+		ipc.disconnect()
+		time.Sleep(50 * time.Millisecond)
+		if _, err := s.Sample(); err != system.ErrNoActiveWindow {
+			t.Fatalf("got %v, want %v", err, system.ErrNoActiveWindow)
+		}
+
+		ipc.event("title")
+		waitChange(t, s.FocusChanges())
+	})
+
+	t.Run("Idle time", func(t *testing.T) {
+		if _, err := s.IdleTime(); err != system.ErrIdleTimeUnsupported {
+			t.Errorf("got %v, want %v", err, system.ErrIdleTimeUnsupported)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		s.Close()
+		if _, err := s.Sample(); err != system.ErrCompositorClosed {
+			t.Errorf("got %v, want %v", err, system.ErrCompositorClosed)
+		}
+	})
+}
+
+func TestNewI3ipcError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing.sock")
+	if _, err := system.NewSway(path); err == nil {
+		t.Error("Error expected, got nil")
+	}
+	if _, err := system.NewI3(path); err == nil {
+		t.Error("Error expected, got nil")
+	}
+}
+
+func waitChange(t *testing.T, changes <-chan struct{}) {
+	t.Helper()
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for focus change")
+	}
+}
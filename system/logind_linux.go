@@ -0,0 +1,107 @@
+package system
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	logindService   = "org.freedesktop.login1"
+	logindPath      = dbus.ObjectPath("/org/freedesktop/login1")
+	logindManager   = "org.freedesktop.login1.Manager"
+	logindSession   = "org.freedesktop.login1.Session"
+	sessionIDEnv    = "XDG_SESSION_ID"
+	prepareForSleep = "PrepareForSleep"
+)
+
+// ErrNoSession is returned when the login session of the process is unknown
+var ErrNoSession = errors.New("login session not found, screen locks are not tracked")
+
+// SessionHandler is notified when the system goes to sleep or wakes up and
+// when the login session is locked or unlocked
+type SessionHandler interface {
+	Sleep(sleeping bool)
+	Lock(locked bool)
+}
+
+// WatchLogind forwards the sleep signals of logind and the lock signals of
+// the login session of the process, received over conn, to h until ctx is
+// cancelled. If the session cannot be found only sleep is watched and an
+// error wrapping ErrNoSession is returned.
+func WatchLogind(ctx context.Context, conn *dbus.Conn, h SessionHandler) error {
+	err := conn.AddMatchSignal(
+		dbus.WithMatchObjectPath(logindPath),
+		dbus.WithMatchInterface(logindManager),
+		dbus.WithMatchMember(prepareForSleep),
+	)
+	if err != nil {
+		return err
+	}
+
+	session, sessionErr := sessionPath(conn)
+	if sessionErr == nil {
+		sessionErr = conn.AddMatchSignal(
+			dbus.WithMatchObjectPath(session),
+			dbus.WithMatchInterface(logindSession),
+		)
+	}
+	if sessionErr != nil {
+		session = ""
+		sessionErr = fmt.Errorf("%w: %v", ErrNoSession, sessionErr)
+	}
+
+	signals := make(chan *dbus.Signal, 16)
+	conn.Signal(signals)
+
+	go func() {
+		defer conn.RemoveSignal(signals)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case signal, ok := <-signals:
+				if !ok {
+					return
+				}
+				handleLogindSignal(signal, session, h)
+			}
+		}
+	}()
+
+	return sessionErr
+}
+
+// handleLogindSignal forwards a single signal to h
+func handleLogindSignal(signal *dbus.Signal, session dbus.ObjectPath, h SessionHandler) {
+	switch {
+	case signal.Path == logindPath && signal.Name == logindManager+"."+prepareForSleep:
+		if len(signal.Body) == 1 {
+			if sleeping, ok := signal.Body[0].(bool); ok {
+				h.Sleep(sleeping)
+			}
+		}
+	case session != "" && signal.Path == session && signal.Name == logindSession+".Lock":
+		h.Lock(true)
+	case session != "" && signal.Path == session && signal.Name == logindSession+".Unlock":
+		h.Lock(false)
+	}
+}
+
+// sessionPath returns the object path of the login session of the process,
+// named by XDG_SESSION_ID or else looked up by process id
+func sessionPath(conn *dbus.Conn) (dbus.ObjectPath, error) {
+	var path dbus.ObjectPath
+	manager := conn.Object(logindService, logindPath)
+
+	if id := os.Getenv(sessionIDEnv); id != "" {
+		err := manager.Call(logindManager+".GetSession", 0, id).Store(&path)
+		return path, err
+	}
+
+	err := manager.Call(logindManager+".GetSessionByPID", 0, uint32(os.Getpid())).Store(&path)
+	return path, err
+}
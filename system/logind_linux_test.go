@@ -0,0 +1,148 @@
+package system_test
+
+import (
+	"github.com/shldhll/hourglass/system"
+
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+const (
+	stubLogindPath    = dbus.ObjectPath("/org/freedesktop/login1")
+	stubLogindManager = "org.freedesktop.login1.Manager"
+	stubSessionPath   = dbus.ObjectPath("/org/freedesktop/login1/session/c1")
+)
+
+// startBus starts a private message bus and returns its address
+func startBus(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("dbus-daemon"); err != nil {
+		t.Skip("dbus-daemon not found")
+	}
+
+	address := "unix:path=" + filepath.Join(t.TempDir(), "bus.sock")
+	cmd := exec.Command("dbus-daemon", "--session", "--nofork", "--address="+address)
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	t.Cleanup(func() {
+		cmd.Process.Kill()
+		cmd.Wait()
+	})
+	return address
+}
+
+func connectBus(t *testing.T, address string) *dbus.Conn {
+	t.Helper()
+	var conn *dbus.Conn
+	var err error
+	for i := 0; i < 50; i++ {
+		if conn, err = dbus.Connect(address); err == nil {
+			t.Cleanup(func() { conn.Close() })
+			return conn
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("No error expected, got %v", err)
+	return nil
+}
+
+// stubLogind answers the session lookups of WatchLogind
+type stubLogind struct{}
+
+func (stubLogind) GetSession(id string) (dbus.ObjectPath, *dbus.Error) {
+	return stubSessionPath, nil
+}
+
+func (stubLogind) GetSessionByPID(pid uint32) (dbus.ObjectPath, *dbus.Error) {
+	return stubSessionPath, nil
+}
+
+// stubSessionHandler records the events it receives
+type stubSessionHandler struct {
+	events chan string
+}
+
+func (s stubSessionHandler) Sleep(sleeping bool) {
+	if sleeping {
+		s.events <- "sleep"
+	} else {
+		s.events <- "wake"
+	}
+}
+
+func (s stubSessionHandler) Lock(locked bool) {
+	if locked {
+		s.events <- "lock"
+	} else {
+		s.events <- "unlock"
+	}
+}
+
+func (s stubSessionHandler) next(t *testing.T) string {
+	t.Helper()
+	select {
+	case event := <-s.events:
+		return event
+	case <-time.After(time.Second):
+		return "timeout"
+	}
+}
+
+func TestWatchLogind(t *testing.T) {
+	address := startBus(t)
+	logind := connectBus(t, address)
+	conn := connectBus(t, address)
+
+	t.Run("Session unknown", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		h := stubSessionHandler{events: make(chan string, 10)}
+
+		err := system.WatchLogind(ctx, conn, h)
+		if !errors.Is(err, system.ErrNoSession) {
+			t.Fatalf("got %v, want %v", err, system.ErrNoSession)
+		}
+
+		logind.Emit(stubLogindPath, stubLogindManager+".PrepareForSleep", true)
+		if got := h.next(t); got != "sleep" {
+			t.Errorf("got %q, want %q", got, "sleep")
+		}
+	})
+
+	err := logind.Export(stubLogind{}, stubLogindPath, stubLogindManager)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if _, err = logind.RequestName("org.freedesktop.login1", dbus.NameFlagDoNotQueue); err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+
+	t.Run("Session signals", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		h := stubSessionHandler{events: make(chan string, 10)}
+
+		if err := system.WatchLogind(ctx, conn, h); err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+
+		logind.Emit(stubLogindPath, stubLogindManager+".PrepareForSleep", true)
+		logind.Emit(stubLogindPath, stubLogindManager+".PrepareForSleep", false)
+		logind.Emit("/org/freedesktop/login1/session/c2", "org.freedesktop.login1.Session.Lock")
+		logind.Emit(stubSessionPath, "org.freedesktop.login1.Session.Lock")
+		logind.Emit(stubSessionPath, "org.freedesktop.login1.Session.Unlock")
+
+		for _, want := range []string{"sleep", "wake", "lock", "unlock"} {
+			if got := h.next(t); got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		}
+	})
+}
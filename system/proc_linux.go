@@ -0,0 +1,28 @@
+package system
+
+import (
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// procRoot is where the proc filesystem is mounted
+var procRoot = "/proc"
+
+// processInfo returns the executable path and the command line of the
+// process with the given pid. Fields which cannot be read are left empty.
+func processInfo(pid int) (exe string, cmdline []string) {
+	if pid <= 0 {
+		return
+	}
+	dir := procRoot + "/" + strconv.Itoa(pid)
+
+	exe, _ = os.Readlink(dir + "/exe")
+
+	value, err := ioutil.ReadFile(dir + "/cmdline")
+	if err == nil && len(value) > 0 {
+		cmdline = strings.Split(strings.TrimRight(string(value), "\x00"), "\x00")
+	}
+	return
+}
@@ -1,49 +1,112 @@
 package system
 
 import (
+	"errors"
 	"log"
 	"os/exec"
+	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	windowIDSplitSep   = "_NET_ACTIVE_WINDOW(WINDOW): window id # "
-	windowNameSplitSep = ") = "
+	windowIDSplitSep       = "_NET_ACTIVE_WINDOW(WINDOW): window id # "
+	windowPropertySplitSep = " = "
 )
 
+// windowProperties are the properties of the focused window queried by xprop
+var windowProperties = []string{"_NET_WM_NAME", "WM_NAME", "WM_CLASS", "_NET_WM_PID"}
+
+// ErrNoActiveWindow is returned when no window is focused
+var ErrNoActiveWindow = errors.New("no active window")
+
 // Current represents the current operating system
 type Current struct{}
 
-// GetApplicationName returns the name of current forground application
-func (c Current) GetApplicationName() (appName string) {
+// Sample returns information about the current forground window
+func (c Current) Sample() (Sample, error) {
+	var sample Sample
+
 	windowIDCmd, err := exec.Command("xprop", "-root", "_NET_ACTIVE_WINDOW").Output()
 	if err != nil {
-		log.Fatal(err)
-		return
+		return sample, err
 	}
 
-	windowIDCmdSplitRes := strings.Split(string(windowIDCmd), windowIDSplitSep)
-	if len(windowIDCmdSplitRes) <= 1 {
-		return
+	windowIDCmdSplitRes := strings.Split(strings.TrimSpace(string(windowIDCmd)), windowIDSplitSep)
+	if len(windowIDCmdSplitRes) <= 1 || windowIDCmdSplitRes[1] == "0x0" {
+		return sample, ErrNoActiveWindow
 	}
 
 	windowID := windowIDCmdSplitRes[1]
-	windowNameCmd, err := exec.Command("xprop", "-id", windowID, "WM_NAME").Output()
+	windowPropertiesCmd, err := exec.Command("xprop", append([]string{"-id", windowID}, windowProperties...)...).Output()
 	if err != nil {
-		return
+		return sample, err
 	}
 
-	windowNameCmdSplitRes := strings.Split(string(windowNameCmd), windowNameSplitSep)
-	if len(windowNameCmdSplitRes) <= 1 {
-		return
+	sample = parseXprop(string(windowPropertiesCmd))
+	sample.Exe, sample.Cmdline = processInfo(sample.PID)
+
+	return sample, nil
+}
+
+// parseXprop extracts the window properties from the output of xprop
+func parseXprop(output string) Sample {
+	var sample Sample
+	var wmName string
+
+	for _, line := range strings.Split(output, "\n") {
+		lineSplitRes := strings.SplitN(line, windowPropertySplitSep, 2)
+		if len(lineSplitRes) <= 1 {
+			continue
+		}
+		name := lineSplitRes[0]
+		if i := strings.Index(name, "("); i >= 0 {
+			name = name[:i]
+		}
+		value := lineSplitRes[1]
+
+		switch name {
+		case "_NET_WM_NAME":
+			sample.Title = unquote(value)
+		case "WM_NAME":
+			wmName = unquote(value)
+		case "WM_CLASS":
+			classSplitRes := strings.Split(value, ", ")
+			sample.Class = unquote(classSplitRes[len(classSplitRes)-1])
+		case "_NET_WM_PID":
+			sample.PID, _ = strconv.Atoi(strings.TrimSpace(value))
+		}
 	}
 
-	windowName := windowNameCmdSplitRes[1]
-	windowNameSplitRes := strings.Split(strings.ReplaceAll(windowName, "\"", ""), " - ")
-	appName = windowNameSplitRes[len(windowNameSplitRes)-1]
+	if sample.Title == "" {
+		sample.Title = wmName
+	}
+	return sample
+}
+
+// unquote removes the quotes xprop puts around string values
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if unquoted, err := strconv.Unquote(value); err == nil {
+		return unquoted
+	}
+	return strings.Trim(value, "\"")
+}
+
+// IdleTime returns the time elapsed since the last user input, as reported
+// by the X screensaver extension through xprintidle
+func (c Current) IdleTime() (time.Duration, error) {
+	idleCmd, err := exec.Command("xprintidle").Output()
+	if err != nil {
+		return 0, err
+	}
+
+	idleMillis, err := strconv.ParseInt(strings.TrimSpace(string(idleCmd)), 10, 64)
+	if err != nil {
+		return 0, err
+	}
 
-	return appName
+	return time.Duration(idleMillis) * time.Millisecond, nil
 }
 
 // Now returns current time
@@ -0,0 +1,67 @@
+package system
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+const stubXprop = `_NET_WM_NAME(UTF8_STRING) = "notes.txt — Editor"
+WM_NAME(STRING) = "notes.txt - Editor"
+WM_CLASS(STRING) = "editor", "Editor"
+_NET_WM_PID(CARDINAL) = 4242
+`
+
+func TestParseXprop(t *testing.T) {
+	t.Run("All properties", func(t *testing.T) {
+		got := parseXprop(stubXprop)
+		want := Sample{Title: "notes.txt — Editor", Class: "Editor", PID: 4242}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("WM_NAME fallback", func(t *testing.T) {
+		got := parseXprop("_NET_WM_NAME:  not found.\nWM_NAME(STRING) = \"Terminal\"\n")
+		want := Sample{Title: "Terminal"}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
+func TestSampleAppName(t *testing.T) {
+	tests := []struct {
+		sample Sample
+		want   string
+	}{
+		{Sample{Title: "notes.txt - Mozilla Firefox", Class: "firefox", Exe: "/usr/lib/firefox/firefox"}, "Mozilla Firefox"},
+		{Sample{Title: "notes.txt - Editor"}, "Editor"},
+		{Sample{Class: "Editor", Exe: "/usr/bin/editor"}, "Editor"},
+		{Sample{Exe: "/usr/bin/editor"}, "editor"},
+		{Sample{}, ""},
+	}
+
+	for _, test := range tests {
+		if got := test.sample.AppName(); got != test.want {
+			t.Errorf("got %q, want %q", got, test.want)
+		}
+	}
+}
+
+func TestProcessInfo(t *testing.T) {
+	exe, cmdline := processInfo(os.Getpid())
+
+	want, err := os.Executable()
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	if exe != want {
+		t.Errorf("got %q, want %q", exe, want)
+	}
+	if !reflect.DeepEqual(cmdline, os.Args) {
+		t.Errorf("got %v, want %v", cmdline, os.Args)
+	}
+}
@@ -0,0 +1,304 @@
+package system
+
+import (
+	"errors"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/screensaver"
+	"github.com/jezek/xgb/xproto"
+)
+
+// maxPropertyLength is the number of 32 bit units read from a property
+const maxPropertyLength = 1 << 16
+
+// x11Atoms are the atoms interned on every connection
+var x11Atoms = []string{"_NET_ACTIVE_WINDOW", "_NET_WM_NAME", "WM_NAME", "WM_CLASS", "_NET_WM_PID"}
+
+// ErrX11Closed is returned by an X11 backend after Close was called
+var ErrX11Closed = errors.New("X11 connection closed")
+
+// X11 reads the focused window directly from the X server. It follows the
+// _NET_ACTIVE_WINDOW property of the root window and the title of the active
+// window through PropertyNotify events instead of polling them and
+// reconnects on the next call after the connection to the server was lost.
+// It is safe for concurrent use.
+type X11 struct {
+	display string
+	changes chan struct{}
+
+	mu     sync.Mutex
+	conn   *x11Conn
+	active xproto.Window
+	closed bool
+}
+
+// x11Conn is a single connection to the X server
+type x11Conn struct {
+	conn        *xgb.Conn
+	root        xproto.Window
+	atoms       map[string]xproto.Atom
+	screensaver error
+}
+
+// NewX11 connects to the X server of the given display. An empty display
+// uses the DISPLAY environment variable.
+func NewX11(display string) (*X11, error) {
+	x := &X11{display: display, changes: make(chan struct{}, 1)}
+	if _, err := x.connection(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// Sample returns information about the current forground window
+func (x *X11) Sample() (Sample, error) {
+	var sample Sample
+
+	c, err := x.connection()
+	if err != nil {
+		return sample, err
+	}
+
+	x.mu.Lock()
+	window := x.active
+	x.mu.Unlock()
+	if window == 0 {
+		return sample, ErrNoActiveWindow
+	}
+
+	title, err := c.property(window, "_NET_WM_NAME")
+	if err != nil {
+		return sample, err
+	}
+	if len(title) == 0 {
+		if title, err = c.property(window, "WM_NAME"); err != nil {
+			return sample, err
+		}
+	}
+	sample.Title = string(title)
+
+	class, err := c.property(window, "WM_CLASS")
+	if err != nil {
+		return sample, err
+	}
+	classSplitRes := strings.Split(strings.TrimRight(string(class), "\x00"), "\x00")
+	sample.Class = classSplitRes[len(classSplitRes)-1]
+
+	pid, err := c.property(window, "_NET_WM_PID")
+	if err != nil {
+		return sample, err
+	}
+	if len(pid) >= 4 {
+		sample.PID = int(xgb.Get32(pid))
+	}
+
+	sample.Exe, sample.Cmdline = processInfo(sample.PID)
+
+	return sample, nil
+}
+
+// IdleTime returns the time elapsed since the last user input, as reported
+// by the X screensaver extension
+func (x *X11) IdleTime() (time.Duration, error) {
+	c, err := x.connection()
+	if err != nil {
+		return 0, err
+	}
+	if c.screensaver != nil {
+		return 0, c.screensaver
+	}
+
+	info, err := screensaver.QueryInfo(c.conn, xproto.Drawable(c.root)).Reply()
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Duration(info.MsSinceUserInput) * time.Millisecond, nil
+}
+
+// Now returns current time
+func (x *X11) Now() time.Time {
+	return time.Now()
+}
+
+// Log is used for system specific logging
+func (x *X11) Log(msg string) {
+	log.Print(msg)
+}
+
+// FocusChanges returns a channel receiving a value whenever the active window
+// or its title changes. It is closed by Close.
+func (x *X11) FocusChanges() <-chan struct{} {
+	return x.changes
+}
+
+// Close closes the connection to the X server
+func (x *X11) Close() error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if !x.closed {
+		close(x.changes)
+	}
+	x.closed = true
+	if x.conn != nil {
+		x.conn.conn.Close()
+		x.conn = nil
+	}
+	return nil
+}
+
+// connection returns the connection to the X server, connecting first if
+// there is none
+func (x *X11) connection() (*x11Conn, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.closed {
+		return nil, ErrX11Closed
+	}
+	if x.conn != nil {
+		return x.conn, nil
+	}
+
+	c, err := dialX11(x.display)
+	if err != nil {
+		return nil, err
+	}
+	active, err := c.activeWindow()
+	if err != nil {
+		c.conn.Close()
+		return nil, err
+	}
+
+	x.conn = c
+	x.active = active
+	c.follow(active)
+	go x.watch(c)
+
+	return c, nil
+}
+
+// watch follows the active window until the connection is lost
+func (x *X11) watch(c *x11Conn) {
+	for {
+		ev, err := c.conn.WaitForEvent()
+		if ev == nil && err == nil {
+			break
+		}
+
+		notify, ok := ev.(xproto.PropertyNotifyEvent)
+		if !ok {
+			continue
+		}
+
+		if notify.Window == c.root && notify.Atom == c.atoms["_NET_ACTIVE_WINDOW"] {
+			active, activeErr := c.activeWindow()
+			if activeErr != nil {
+				continue
+			}
+			c.follow(active)
+			x.mu.Lock()
+			if x.conn == c && x.active != active {
+				x.active = active
+				x.notify()
+			}
+			x.mu.Unlock()
+			continue
+		}
+
+		if notify.Atom == c.atoms["_NET_WM_NAME"] || notify.Atom == c.atoms["WM_NAME"] {
+			x.mu.Lock()
+			if x.conn == c && x.active == notify.Window {
+				x.notify()
+			}
+			x.mu.Unlock()
+		}
+	}
+
+	x.mu.Lock()
+	if x.conn == c {
+		x.conn = nil
+		x.active = 0
+	}
+	x.mu.Unlock()
+}
+
+// notify reports a change without waiting for it to be received. It must be
+// called with x.mu held.
+func (x *X11) notify() {
+	if x.closed {
+		return
+	}
+	select {
+	case x.changes <- struct{}{}:
+	default:
+	}
+}
+
+// dialX11 connects to the X server and subscribes to the property changes
+// of the root window
+func dialX11(display string) (*x11Conn, error) {
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &x11Conn{
+		conn:  conn,
+		root:  xproto.Setup(conn).DefaultScreen(conn).Root,
+		atoms: make(map[string]xproto.Atom),
+	}
+
+	for _, name := range x11Atoms {
+		reply, err := xproto.InternAtom(conn, false, uint16(len(name)), name).Reply()
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		c.atoms[name] = reply.Atom
+	}
+
+	err = xproto.ChangeWindowAttributesChecked(conn, c.root, xproto.CwEventMask,
+		[]uint32{xproto.EventMaskPropertyChange}).Check()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.screensaver = screensaver.Init(conn)
+
+	return c, nil
+}
+
+// activeWindow reads the _NET_ACTIVE_WINDOW property of the root window
+func (c *x11Conn) activeWindow() (xproto.Window, error) {
+	value, err := c.property(c.root, "_NET_ACTIVE_WINDOW")
+	if err != nil || len(value) < 4 {
+		return 0, err
+	}
+	return xproto.Window(xgb.Get32(value)), nil
+}
+
+// follow subscribes to the property changes of the window, so changes of
+// its title are reported
+func (c *x11Conn) follow(window xproto.Window) {
+	if window != 0 {
+		xproto.ChangeWindowAttributes(c.conn, window, xproto.CwEventMask, []uint32{xproto.EventMaskPropertyChange})
+	}
+}
+
+// property returns the value of the named property of the window, or nil if
+// the window does not have it
+func (c *x11Conn) property(window xproto.Window, name string) ([]byte, error) {
+	reply, err := xproto.GetProperty(c.conn, false, window, c.atoms[name],
+		xproto.GetPropertyTypeAny, 0, maxPropertyLength).Reply()
+	if err != nil {
+		return nil, err
+	}
+	return reply.Value, nil
+}
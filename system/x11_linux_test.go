@@ -0,0 +1,186 @@
+package system_test
+
+import (
+	"github.com/shldhll/hourglass/system"
+
+	"fmt"
+	"os"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/jezek/xgb"
+	"github.com/jezek/xgb/xproto"
+)
+
+// startXvfb starts a virtual X server and returns its display
+func startXvfb(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("Xvfb"); err != nil {
+		t.Skip("Xvfb not found")
+	}
+
+	for n := 90; n < 100; n++ {
+		if _, err := os.Stat(fmt.Sprintf("/tmp/.X11-unix/X%d", n)); err == nil {
+			continue
+		}
+
+		display := fmt.Sprintf(":%d", n)
+		cmd := exec.Command("Xvfb", display, "-nolisten", "tcp")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		t.Cleanup(func() {
+			cmd.Process.Kill()
+			cmd.Wait()
+		})
+
+		for i := 0; i < 50; i++ {
+			if conn, err := xgb.NewConnDisplay(display); err == nil {
+				conn.Close()
+				return display
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		t.Fatal("Xvfb did not start")
+	}
+	t.Skip("no free display")
+	return ""
+}
+
+// stubWindowManager sets the properties a window manager would set
+type stubWindowManager struct {
+	t    *testing.T
+	conn *xgb.Conn
+	root xproto.Window
+}
+
+func newStubWindowManager(t *testing.T, display string) *stubWindowManager {
+	t.Helper()
+	conn, err := xgb.NewConnDisplay(display)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	t.Cleanup(conn.Close)
+
+	return &stubWindowManager{t: t, conn: conn, root: xproto.Setup(conn).DefaultScreen(conn).Root}
+}
+
+func (s *stubWindowManager) atom(name string) xproto.Atom {
+	reply, err := xproto.InternAtom(s.conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		s.t.Fatalf("No error expected, got %v", err)
+	}
+	return reply.Atom
+}
+
+func (s *stubWindowManager) set(window xproto.Window, name, typeName string, format byte, value []byte) {
+	err := xproto.ChangePropertyChecked(s.conn, xproto.PropModeReplace, window, s.atom(name), s.atom(typeName),
+		format, uint32(len(value)*8/int(format)), value).Check()
+	if err != nil {
+		s.t.Fatalf("No error expected, got %v", err)
+	}
+}
+
+func (s *stubWindowManager) window(title, class string, pid uint32) xproto.Window {
+	window, err := xproto.NewWindowId(s.conn)
+	if err != nil {
+		s.t.Fatalf("No error expected, got %v", err)
+	}
+	err = xproto.CreateWindowChecked(s.conn, 0, window, s.root, 0, 0, 10, 10, 0,
+		xproto.WindowClassInputOutput, 0, 0, nil).Check()
+	if err != nil {
+		s.t.Fatalf("No error expected, got %v", err)
+	}
+
+	pidValue := make([]byte, 4)
+	xgb.Put32(pidValue, pid)
+	s.set(window, "_NET_WM_NAME", "UTF8_STRING", 8, []byte(title))
+	s.set(window, "WM_CLASS", "STRING", 8, []byte(class+"\x00"+class+"\x00"))
+	s.set(window, "_NET_WM_PID", "CARDINAL", 32, pidValue)
+	return window
+}
+
+func (s *stubWindowManager) activate(window xproto.Window) {
+	value := make([]byte, 4)
+	xgb.Put32(value, uint32(window))
+	s.set(s.root, "_NET_ACTIVE_WINDOW", "WINDOW", 32, value)
+}
+
+func TestX11(t *testing.T) {
+	display := startXvfb(t)
+	wm := newStubWindowManager(t, display)
+	editor := wm.window("notes.txt - Editor", "Editor", 4242)
+	browser := wm.window("News - Browser", "Browser", 4343)
+	wm.activate(editor)
+
+	x, err := system.NewX11(display)
+	if err != nil {
+		t.Fatalf("No error expected, got %v", err)
+	}
+	defer x.Close()
+
+	t.Run("Active window", func(t *testing.T) {
+		got, err := x.Sample()
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if got.Title != "notes.txt - Editor" || got.Class != "Editor" || got.PID != 4242 {
+			t.Errorf("got %v, want the editor window", got)
+		}
+	})
+
+	t.Run("Active window changed", func(t *testing.T) {
+		wm.activate(browser)
+		waitChange(t, x.FocusChanges())
+
+		got, err := x.Sample()
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if got.Class != "Browser" {
+			t.Errorf("got %v, want the browser window", got)
+		}
+	})
+
+	t.Run("Title changed", func(t *testing.T) {
+		wm.set(browser, "_NET_WM_NAME", "UTF8_STRING", 8, []byte("Weather - Browser"))
+		waitChange(t, x.FocusChanges())
+
+		got, err := x.Sample()
+		if err != nil {
+			t.Fatalf("No error expected, got %v", err)
+		}
+		if got.Title != "Weather - Browser" {
+			t.Errorf("got %q, want %q", got.Title, "Weather - Browser")
+		}
+	})
+
+	t.Run("Idle time", func(t *testing.T) {
+		if _, err := x.IdleTime(); err != nil {
+			t.Errorf("No error expected, got %v", err)
+		}
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		x.Close()
+		if _, err := x.Sample(); err != system.ErrX11Closed {
+			t.Errorf("got %v, want %v", err, system.ErrX11Closed)
+		}
+		// at most one change is pending before the channel is closed
+		_, ok := <-x.FocusChanges()
+		if ok {
+			_, ok = <-x.FocusChanges()
+		}
+		if ok {
+			t.Error("FocusChanges() not closed")
+		}
+	})
+}
+
+func TestNewX11Error(t *testing.T) {
+	_, err := system.NewX11(":-1")
+	if err == nil {
+		t.Error("Error expected, got nil")
+	}
+}
@@ -4,28 +4,39 @@ import (
 	"github.com/shldhll/hourglass/data"
 	"github.com/shldhll/hourglass/system"
 
-	"fmt"
-	"strings"
+	"context"
+	"sync"
 	"time"
 )
 
 const (
-	// EntryIDStringFormat is the format used for Sprintf function to create the ID for database entry
-	EntryIDStringFormat = "%v_%s"
-	// EntryIDDateFormat is the date format used in the ID
-	EntryIDDateFormat = "2006-01-02"
-	// EntryIDNameReplaceOld is the string which is to be replaced by EntryIDNameReplaceNew
-	EntryIDNameReplaceOld = " "
-	// EntryIDNameReplaceNew is the string which replaces EntryIDNameReplaceOld
-	EntryIDNameReplaceNew = ""
-
 	// DBCallNoReturn is used when call to database times out
 	DBCallNoReturn = "Call to DB did not return"
+	// ErrSampleText is used as prefix text when the focused window cannot be read
+	ErrSampleText = "Could not read focused window: "
+	// ErrIdleTimeText is used as prefix text when idle time cannot be read
+	ErrIdleTimeText = "Could not read idle time: "
+	// ErrDayStartText is used when the configured start of a day does not lie
+	// within the first 24 hours after midnight
+	ErrDayStartText = "day start must be at least 0 and less than 24h"
+
+	// BreakText is used as prefix text when the time between two samples is
+	// not credited because the system was suspended or the clock changed
+	BreakText = "Not crediting break between samples: "
+	// BreakGapFactor is the number of cooldowns after which the time between
+	// two samples is considered a break
+	BreakGapFactor = 5
+	// MinBreakGap is the shortest time between two samples considered a break
+	MinBreakGap = 10 * time.Second
+	// MaxClockDrift is the largest difference between the wall clock and the
+	// monotonic clock over two samples not considered a clock change
+	MaxClockDrift = 2 * time.Second
 )
 
 // Task struct represents a running application.
 type Task struct {
 	applicationName string
+	sample          system.Sample
 	recordedTime    time.Time
 }
 
@@ -39,6 +50,16 @@ func (t Task) AppName() string {
 	return t.applicationName
 }
 
+// Title returns the title of the focused window
+func (t Task) Title() string {
+	return t.sample.Title
+}
+
+// Sample returns the window information the task was created from
+func (t Task) Sample() system.Sample {
+	return t.sample
+}
+
 // NewTask creates a new task
 func NewTask(appName string, recordedTime time.Time) *Task {
 	return &Task{
@@ -47,66 +68,412 @@ func NewTask(appName string, recordedTime time.Time) *Task {
 	}
 }
 
-// Start is the entrypoint function
-func Start(o system.OS, db data.DB, cfg system.Config) {
-	var prevApp string
-	prevTime := o.Now()
-	cooldownTime := cfg.GetCooldownTime()
-	minUsageTime := cfg.GetMinUsageTime()
-	entryDict := make(map[string]data.Entry)
+// NewTaskFromSample creates a new task for the application of the sample
+func NewTaskFromSample(sample system.Sample, recordedTime time.Time) *Task {
+	return &Task{
+		applicationName: sample.AppName(),
+		sample:          sample,
+		recordedTime:    recordedTime,
+	}
+}
+
+// Status represents the live state of a running tracker. It is safe for
+// concurrent use and implements system.SessionHandler.
+type Status struct {
+	mu       sync.RWMutex
+	appName  string
+	since    time.Time
+	paused   bool
+	until    time.Time
+	sleeping bool
+	locked   bool
+	changes  chan struct{}
+	onChange func(appName string, since time.Time)
+}
+
+// NewStatus creates a new status. onChange, if not nil, is called whenever
+// the tracked application changes.
+func NewStatus(onChange func(appName string, since time.Time)) *Status {
+	return &Status{onChange: onChange, changes: make(chan struct{}, 1)}
+}
+
+// Current returns the application currently tracked and since when
+func (s *Status) Current() (string, time.Time) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.appName, s.since
+}
+
+// Pause stops crediting applications until Resume is called or, if until is
+// not zero, until that time has passed
+func (s *Status) Pause(until time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = true
+	s.until = until
+	s.notify()
+}
+
+// Resume resumes crediting applications
+func (s *Status) Resume() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.paused = false
+	s.until = time.Time{}
+	s.notify()
+}
+
+// Sleep records the system going to sleep or waking up
+func (s *Status) Sleep(sleeping bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sleeping = sleeping
+	s.notify()
+}
+
+// Lock records the screen being locked or unlocked
+func (s *Status) Lock(locked bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.locked = locked
+	s.notify()
+}
+
+// notify wakes up the tracker to sample right away. It must be called with
+// s.mu held.
+func (s *Status) notify() {
+	select {
+	case s.changes <- struct{}{}:
+	default:
+	}
+}
+
+// changed returns the channel receiving a value whenever the tracker should
+// sample right away
+func (s *Status) changed() <-chan struct{} {
+	if s == nil {
+		return nil
+	}
+	return s.changes
+}
+
+// awayAt returns the application name to record instead of the focused
+// application at the given time, or an empty string
+func (s *Status) awayAt(now time.Time) string {
+	if s == nil {
+		return ""
+	}
+	if paused, _ := s.pausedAt(now); paused {
+		return data.PausedAppName
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	switch {
+	case s.sleeping:
+		return data.SleepAppName
+	case s.locked:
+		return data.LockedAppName
+	}
+	return ""
+}
+
+// Paused reports whether tracking is paused and until when. A zero time
+// means tracking is paused until Resume is called.
+func (s *Status) Paused() (bool, time.Time) {
+	return s.pausedAt(time.Now())
+}
+
+// pausedAt reports whether tracking is paused at the given time, resuming
+// tracking once the pause has expired
+func (s *Status) pausedAt(now time.Time) (bool, time.Time) {
+	if s == nil {
+		return false, time.Time{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.paused && !s.until.IsZero() && !now.Before(s.until) {
+		s.paused = false
+		s.until = time.Time{}
+	}
+	return s.paused, s.until
+}
+
+// set records a change of the tracked application
+func (s *Status) set(appName string, since time.Time) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	s.appName = appName
+	s.since = since
+	s.mu.Unlock()
+
+	if s.onChange != nil {
+		s.onChange(appName, since)
+	}
+}
+
+// Start is the entrypoint function. It tracks until ctx is cancelled or
+// cfg.LoopCheck returns false and writes the span still in progress before
+// returning. Spans are written in order by a single goroutine, and Start
+// waits for the database once SpanBufferSize spans are queued. status may be
+// nil. If o is a system.Watcher, a sample is taken
+// as soon as the focus changes instead of at the next tick only. Spans
+// crossing the start of a day, as set by cfg.GetDayStart, are split there.
+// Idle spans start with the last input, so the idle threshold is not
+// credited to the application focused before.
+func Start(ctx context.Context, o system.OS, db data.DB, cfg system.Config, status *Status) {
+	s := sampler{o: o, status: status}
+	w := newWriter(db, o.Log)
+
+	var changes <-chan struct{}
+	if w, ok := o.(system.Watcher); ok {
+		changes = w.FocusChanges()
+	}
+
+	// the first sample already reflects changes of status made so far
+	select {
+	case <-status.changed():
+	default:
+	}
+
+	prevTask, _ := s.sample(cfg.GetIdleThreshold())
+	prevApp := prevTask.AppName()
+	prevTime := prevTask.Time()
+	lastTime := prevTime
+	session := newSession(prevTask)
+	status.set(prevApp, prevTime)
+
+	// write queues the span of prevApp ending at endTime
+	write := func(endTime time.Time, timeout time.Duration) {
+		session.End = endTime
+		w.write(span{
+			entry:   newEntry(prevApp, data.Day(prevTime, cfg.GetDayStart()), endTime.Sub(prevTime)),
+			session: session,
+		}, timeout)
+	}
+
+	// credit writes the span of prevApp ending at endTime, split at the start
+	// of every day it crosses so that each part is credited to its own day
+	credit := func(endTime time.Time, timeout time.Duration) {
+		for {
+			dayStart := cfg.GetDayStart()
+			next := data.StartOfDay(data.Day(prevTime, dayStart).AddDate(0, 0, 1), dayStart)
+			if !next.Before(endTime) {
+				break
+			}
+			write(next, timeout)
+			prevTime = next
+			session.Start = next
+		}
+		write(endTime, timeout)
+	}
 
 	for cfg.LoopCheck() {
-		task := Ping(o)
+		cooldownTime := cfg.GetCooldownTime()
+		select {
+		case <-ctx.Done():
+		case <-time.After(cooldownTime):
+		case <-status.changed():
+		case _, ok := <-changes:
+			if !ok {
+				changes = nil
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		task, lastInput := s.sample(cfg.GetIdleThreshold())
 		currApp := task.AppName()
 		currTime := task.Time()
-		errChan := make(chan error)
-
-		if diff := currTime.Sub(prevTime); diff >= minUsageTime && currApp == prevApp {
-			go func(database data.DB, appName string, startTime, endTime time.Time, errorChan chan<- error) {
-				duration := endTime.Sub(startTime)
-				id := CreateID(appName, startTime)
-				entry := data.Entry{
-					ID:       id,
-					AppName:  appName,
-					Duration: duration,
-				}
-
-				err := db.Write(entry)
-				prevTime = currTime
-				if _, ok := entryDict[entry.ID]; !ok && err == nil {
-					err = db.WriteList(entry)
-				}
-				errorChan <- err
-			}(db, currApp, prevTime, currTime, errChan)
+
+		if prevApp != data.SleepAppName && isBreak(lastTime, currTime, cooldownTime) {
+			o.Log(BreakText + lastTime.Format(time.RFC3339) + " - " + currTime.Format(time.RFC3339))
+			if lastTime.Sub(prevTime) >= cfg.GetMinUsageTime() {
+				credit(lastTime, cooldownTime)
+			}
+			prevTime = currTime
+			session.Start = currTime
+		}
+
+		// time without input is held back from the focused application until
+		// input is received again, and goes to the idle span once the idle
+		// threshold is reached, which starts with the last input
+		end := currTime
+		if prevApp != data.IdleAppName && (currApp == prevApp || currApp == data.IdleAppName) {
+			end = lastInput
+			if end.Before(prevTime) {
+				end = prevTime
+			}
+		}
+
+		if diff := end.Sub(prevTime); diff >= cfg.GetMinUsageTime() {
+			credit(end, cooldownTime)
+			prevTime = end
 		}
 
 		if prevApp != currApp {
 			prevApp = currApp
-			prevApp = currApp
+			prevTime = end
+			status.set(currApp, end)
 		}
 
-		select {
-		case err := <-errChan:
-			if err != nil {
-				o.Log(err.Error())
-			}
-		case <-time.After(cooldownTime):
-			cfg.LoopNext()
+		if next := newSession(task); session.AppName != next.AppName || session.Title != next.Title || session.PID != next.PID {
+			session = next
+			session.Start = prevTime
 		}
 
+		lastTime = currTime
+		w.report()
 		cfg.LoopNext()
 	}
+
+	currTime := o.Now()
+	if diff := currTime.Sub(prevTime); diff >= cfg.GetMinUsageTime() {
+		credit(currTime, cfg.GetCooldownTime())
+	}
+	w.close()
 }
 
-// Ping returns window information in the form of Task struct.
-func Ping(o system.OS) *Task {
-	return NewTask(o.GetApplicationName(), o.Now())
+// isBreak reports whether the time between two samples taken about a
+// cooldown apart cannot have been spent in an application: either much more
+// time than expected passed, as when the system was suspended, or the wall
+// clock was changed in between
+func isBreak(last, curr time.Time, cooldown time.Duration) bool {
+	gap := BreakGapFactor * cooldown
+	if gap < MinBreakGap {
+		gap = MinBreakGap
+	}
+
+	wall := curr.Round(0).Sub(last.Round(0))
+	if wall < 0 || wall > gap {
+		return true
+	}
+
+	// Sub uses the monotonic clock when both times carry its reading
+	drift := wall - curr.Sub(last)
+	return drift > MaxClockDrift || drift < -MaxClockDrift
 }
 
-// CreateID creates an ID string using formatted date and string
-func CreateID(appName string, date time.Time) string {
-	formattedDate := date.Format(EntryIDDateFormat)
-	formattedAppName := strings.ReplaceAll(appName, EntryIDNameReplaceOld, EntryIDNameReplaceNew)
+// newEntry creates the entry crediting appName with duration on day
+func newEntry(appName string, day time.Time, duration time.Duration) data.Entry {
+	return data.Entry{
+		ID:       data.CreateID(appName, day),
+		AppName:  appName,
+		Duration: duration,
+	}
+}
+
+// writeSpan adds the entry to the database, listed for its day, and stores
+// the session it belongs to
+func writeSpan(db data.DB, entry data.Entry, session data.Session) error {
+	if err := db.AddEntry(entry); err != nil {
+		return err
+	}
+	return db.WriteSession(session)
+}
+
+// newSession starts a session for the window of the given task
+func newSession(task *Task) data.Session {
+	sample := task.Sample()
+	return data.Session{
+		AppName: task.AppName(),
+		Title:   sample.Title,
+		Class:   sample.Class,
+		PID:     sample.PID,
+		Exe:     sample.Exe,
+		Cmdline: sample.Cmdline,
+		Start:   task.Time(),
+	}
+}
+
+// sampler pings the OS, logging each distinct error only once in a row
+type sampler struct {
+	o             system.OS
+	status        *Status
+	lastErr       string
+	idleErrLogged bool
+}
+
+// sample pings the OS and substitutes data.PausedAppName for the application
+// name while tracking is paused, data.SleepAppName or data.LockedAppName while
+// the system sleeps or the screen is locked and data.IdleAppName once no input
+// has been received for at least idleThreshold. It also returns the time input was
+// last received, which is the sample time if the idle time is not known.
+func (s *sampler) sample(idleThreshold time.Duration) (*Task, time.Time) {
+	task, err := ping(s.o)
+	if err != nil {
+		if err.Error() != s.lastErr {
+			s.o.Log(ErrSampleText + err.Error())
+			s.lastErr = err.Error()
+		}
+	} else {
+		s.lastErr = ""
+	}
+
+	if appName := s.status.awayAt(task.Time()); appName != "" {
+		return NewTask(appName, task.Time()), task.Time()
+	}
+	if idleThreshold <= 0 {
+		return task, task.Time()
+	}
+
+	idle, err := s.o.IdleTime()
+	if err != nil {
+		if !s.idleErrLogged {
+			s.o.Log(ErrIdleTimeText + err.Error())
+			s.idleErrLogged = true
+		}
+		return task, task.Time()
+	}
+
+	lastInput := task.Time().Add(-idle)
+	if idle >= idleThreshold {
+		return NewTask(data.IdleAppName, task.Time()), lastInput
+	}
+	return task, lastInput
+}
+
+// Totals sums up the given sessions into one entry per application and day,
+// keyed the same way Start keys the entries it writes for days starting
+// dayStart after midnight
+func Totals(sessions []data.Session, dayStart time.Duration) []data.Entry {
+	entryList := []data.Entry{}
+	index := make(map[string]int)
+
+	for _, session := range sessions {
+		id := data.CreateID(session.AppName, data.Day(session.Start, dayStart))
+		i, ok := index[id]
+		if !ok {
+			i = len(entryList)
+			index[id] = i
+			entryList = append(entryList, data.Entry{ID: id, AppName: session.AppName})
+		}
+		entryList[i].Duration += session.Duration()
+	}
+
+	return entryList
+}
+
+// Ping returns window information in the form of Task struct. Errors of
+// the OS result in a task without an application name.
+func Ping(o system.OS) *Task {
+	task, _ := ping(o)
+	return task
+}
 
-	return fmt.Sprintf(EntryIDStringFormat, formattedDate, formattedAppName)
+func ping(o system.OS) (*Task, error) {
+	sample, err := o.Sample()
+	return NewTaskFromSample(sample, o.Now()), err
 }
@@ -2,14 +2,15 @@ package tracker_test
 
 import (
 	"github.com/shldhll/hourglass/data"
+	"github.com/shldhll/hourglass/system"
 	"github.com/shldhll/hourglass/tracker"
 
+	"context"
 	"time"
 	"errors"
 	"reflect"
 	"testing"
 	"strings"
-	"fmt"
 )
 
 var (
@@ -19,27 +20,52 @@ var (
 
 const (
 	stubName         = "App Name"
-	stubCooldownTime = 1 * time.Second
+	stubTitle        = "Document - App Name"
+	stubCooldownTime = 100 * time.Millisecond
 	stubMinUsageTime = 1 * time.Nanosecond
 	stubDuration     = 1 * time.Hour
 )
 
 type stubOS struct {
 	applicationName  string
+	windowTitle      string
 	realTime         bool
-	getAppNameCalled int
+	pid              int
+	sampleErr        error
+	sampleCalled     int
 	nowCalled        int
 	shouldLog        int
 	logChan          chan string
+	idleTime         time.Duration
+	idleErr          error
+	idleTimes        []time.Duration
+	times            []time.Time
 }
 
-func (s *stubOS) GetApplicationName() string {
-	s.getAppNameCalled++
-	return s.applicationName
+func (s *stubOS) Sample() (system.Sample, error) {
+	s.sampleCalled++
+	if s.sampleErr != nil {
+		return system.Sample{}, s.sampleErr
+	}
+	return system.Sample{Title: s.windowTitle, Class: s.applicationName, PID: s.pid}, nil
+}
+
+func (s *stubOS) IdleTime() (time.Duration, error) {
+	if len(s.idleTimes) > 0 {
+		idle := s.idleTimes[0]
+		s.idleTimes = s.idleTimes[1:]
+		return idle, s.idleErr
+	}
+	return s.idleTime, s.idleErr
 }
 
 func (s *stubOS) Now() time.Time {
 	s.nowCalled++
+	if len(s.times) > 0 {
+		now := s.times[0]
+		s.times = s.times[1:]
+		return now
+	}
 	if s.realTime {
 		return time.Now()
 	}
@@ -52,16 +78,33 @@ func (s *stubOS) Log(msg string) {
 	}
 }
 
+type stubWatcher struct {
+	stubOS
+	changes chan struct{}
+}
+
+func (s *stubWatcher) FocusChanges() <-chan struct{} {
+	return s.changes
+}
+
 type stubDB struct {
 	showErrorOK int
 	write       int
 	read        int
-	writeList   int
 	readList    int
+	entries     []data.Entry
+	sessions    []data.Session
+	block       chan struct{}
+	delay       time.Duration
 }
 
-func (s *stubDB) Write(entry data.Entry) error {
+func (s *stubDB) AddEntry(entry data.Entry) error {
+	if s.block != nil {
+		<-s.block
+	}
+	time.Sleep(s.delay)
 	s.write++
+	s.entries = append(s.entries, entry)
 	if s.showErrorOK == 0 {
 		return nil
 	}
@@ -78,8 +121,21 @@ func (s *stubDB) Read(key string) (data.Entry, error) {
 	return data.Entry{}, nil
 }
 
-func (s *stubDB) WriteList(entry data.Entry) error {
-	s.writeList++
+func (s *stubDB) ReadList(date string) ([]data.Entry, error) {
+	s.readList++
+	if s.showErrorOK != 0 {
+		return []data.Entry{}, stubDBWriteErr
+	}
+
+	return []data.Entry{}, nil
+}
+
+func (s *stubDB) ReadRange(from, to string) ([]data.Entry, error) {
+	return s.ReadList(from)
+}
+
+func (s *stubDB) WriteSession(session data.Session) error {
+	s.sessions = append(s.sessions, session)
 	if s.showErrorOK != 0 {
 		return stubDBWriteErr
 	}
@@ -87,13 +143,12 @@ func (s *stubDB) WriteList(entry data.Entry) error {
 	return nil
 }
 
-func (s *stubDB) ReadList(date string) ([]data.Entry, error) {
-	s.readList++
+func (s *stubDB) ReadSessions(from, to time.Time) ([]data.Session, error) {
 	if s.showErrorOK != 0 {
-		return []data.Entry{}, stubDBWriteErr
+		return []data.Session{}, stubDBWriteErr
 	}
 
-	return []data.Entry{}, nil
+	return s.sessions, nil
 }
 
 type stubCfg struct {
@@ -105,6 +160,8 @@ type stubCfg struct {
 	loopNextCalled        int
 	cooldownTime          time.Duration
 	minUsageTime          time.Duration
+	idleThreshold         time.Duration
+	dayStart              time.Duration
 }
 
 func (s *stubCfg) GetCooldownTime() time.Duration {
@@ -117,6 +174,14 @@ func (s *stubCfg) GetMinUsageTime() time.Duration {
 	return s.minUsageTime
 }
 
+func (s *stubCfg) GetIdleThreshold() time.Duration {
+	return s.idleThreshold
+}
+
+func (s *stubCfg) GetDayStart() time.Duration {
+	return s.dayStart
+}
+
 func (s *stubCfg) LoopCheck() bool {
 	s.loopCheckCalled++
 	return s.shouldLoop
@@ -130,26 +195,17 @@ func (s *stubCfg) LoopNext() {
 }
 
 func TestPing(t *testing.T) {
-	system := stubOS{
+	o := stubOS{
 		applicationName: stubName,
 	}
-	got := tracker.Ping(&system)
-	want := tracker.NewTask(stubName, stubTime)
+	got := tracker.Ping(&o)
+	want := tracker.NewTaskFromSample(system.Sample{Class: stubName}, stubTime)
 
 	if !reflect.DeepEqual(got, want) {
 		t.Errorf("got %v, want %v", got, want)
 	}
 }
 
-func TestCreateID(t *testing.T) {
-	got := tracker.CreateID(stubName, stubTime)
-	want := fmt.Sprintf(tracker.EntryIDStringFormat, stubTime.Format(tracker.EntryIDDateFormat), strings.ReplaceAll(stubName, tracker.EntryIDNameReplaceOld, tracker.EntryIDNameReplaceNew))
-
-	if got != want {
-		t.Errorf("got %q, want %q", got, want)
-	}
-}
-
 func TestNewTask(t *testing.T) {
 	task := tracker.NewTask(stubName, stubTime)
 
@@ -182,6 +238,55 @@ func TestTaskTime(t *testing.T) {
 	}
 }
 
+func TestTaskTitle(t *testing.T) {
+	system := stubOS{
+		applicationName: stubName,
+		windowTitle:     stubTitle,
+	}
+	got := tracker.Ping(&system).Title()
+	want := stubTitle
+
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestTotals(t *testing.T) {
+	nextDay := stubTime.AddDate(0, 0, 1)
+	sessions := []data.Session{
+		{AppName: stubName, Start: stubTime, End: stubTime.Add(stubDuration)},
+		{AppName: "Other", Start: stubTime.Add(stubDuration), End: stubTime.Add(2 * stubDuration)},
+		{AppName: stubName, Title: stubTitle, Start: stubTime.Add(2 * stubDuration), End: stubTime.Add(3 * stubDuration)},
+		{AppName: stubName, Start: nextDay, End: nextDay.Add(stubDuration)},
+	}
+
+	t.Run("Days starting at midnight", func(t *testing.T) {
+		got := tracker.Totals(sessions, 0)
+		want := []data.Entry{
+			{ID: data.CreateID(stubName, stubTime), AppName: stubName, Duration: 2 * stubDuration},
+			{ID: data.CreateID("Other", stubTime), AppName: "Other", Duration: stubDuration},
+			{ID: data.CreateID(stubName, nextDay), AppName: stubName, Duration: stubDuration},
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Days starting later", func(t *testing.T) {
+		got := tracker.Totals(sessions, 2*stubDuration)
+		want := []data.Entry{
+			{ID: data.CreateID(stubName, stubTime.AddDate(0, 0, -1)), AppName: stubName, Duration: stubDuration},
+			{ID: data.CreateID("Other", stubTime.AddDate(0, 0, -1)), AppName: "Other", Duration: stubDuration},
+			{ID: data.CreateID(stubName, stubTime), AppName: stubName, Duration: 2 * stubDuration},
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+}
+
 func TestStart(t *testing.T) {
 	t.Run("OS functions called", func(t *testing.T) {
 		system := stubOS{
@@ -198,9 +303,9 @@ func TestStart(t *testing.T) {
 			minUsageTime: stubMinUsageTime,
 		}
 
-		tracker.Start(&system, &db, &config)
-		if system.getAppNameCalled == 0 {
-			t.Error("GetApplicationName() not called")
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+		if system.sampleCalled == 0 {
+			t.Error("Sample() not called")
 		}
 		if system.nowCalled == 0 {
 			t.Error("Now() not called")
@@ -222,7 +327,7 @@ func TestStart(t *testing.T) {
 			minUsageTime: stubMinUsageTime,
 		}
 
-		tracker.Start(&system, &db, &config)
+		tracker.Start(context.Background(), &system, &db, &config, nil)
 
 		if db.write == 0 {
 			t.Error("DB not called enough times")
@@ -244,7 +349,7 @@ func TestStart(t *testing.T) {
 			minUsageTime: stubMinUsageTime,
 		}
 
-		tracker.Start(&system, &db, &config)
+		tracker.Start(context.Background(), &system, &db, &config, nil)
 
 		if config.getCooldownTimeCalled == 0 {
 			t.Error("GetCooldownTime() not called")
@@ -263,22 +368,30 @@ func TestStart(t *testing.T) {
 		}
 	})
 
-	t.Run("Database write timeout", func(t *testing.T) {
+	t.Run("Database stall logged", func(t *testing.T) {
+		times := make([]time.Time, tracker.SpanBufferSize+3)
+		for i := range times {
+			times[i] = stubTime.Add(time.Duration(i) * time.Second)
+		}
 		system := stubOS{
 			applicationName: stubName,
-			realTime:        true,
 			shouldLog:       1,
 			logChan:         make(chan string, 10),
+			times:           times,
 		}
-		db := stubDB{}
+		db := stubDB{block: make(chan struct{})}
 		config := stubCfg{
 			shouldLoop:   true,
-			numLoops:     1,
+			numLoops:     len(times) - 2,
 			cooldownTime: stubMinUsageTime,
 			minUsageTime: stubMinUsageTime,
 		}
 
-		tracker.Start(&system, &db, &config)
+		done := make(chan struct{})
+		go func() {
+			tracker.Start(context.Background(), &system, &db, &config, nil)
+			close(done)
+		}()
 
 		select {
 		case msg := <-system.logChan:
@@ -288,6 +401,41 @@ func TestStart(t *testing.T) {
 		case <-time.After(1 * time.Second):
 			t.Errorf("timed out")
 		}
+
+		close(db.block)
+		<-done
+		if got, want := len(db.entries), len(times)-1; got != want {
+			t.Errorf("got %d spans written, want %d", got, want)
+		}
+	})
+
+	t.Run("Spans written in order", func(t *testing.T) {
+		times := make([]time.Time, 3*tracker.SpanBufferSize)
+		for i := range times {
+			times[i] = stubTime.Add(time.Duration(i) * time.Second)
+		}
+		system := stubOS{
+			applicationName: stubName,
+			times:           times,
+		}
+		db := stubDB{delay: time.Millisecond}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     len(times) - 2,
+			cooldownTime: stubMinUsageTime,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		if got, want := len(db.sessions), len(times)-1; got != want {
+			t.Fatalf("got %d sessions written, want %d", got, want)
+		}
+		for i, session := range db.sessions {
+			if want := times[i+1]; !session.End.Equal(want) {
+				t.Errorf("got %v, want %v", session.End, want)
+			}
+		}
 	})
 
 	t.Run("Database write error", func(t *testing.T) {
@@ -305,7 +453,7 @@ func TestStart(t *testing.T) {
 			minUsageTime: stubMinUsageTime,
 		}
 
-		tracker.Start(&system, &db, &config)
+		tracker.Start(context.Background(), &system, &db, &config, nil)
 
 		select {
 		case msg := <-system.logChan:
@@ -316,4 +464,539 @@ func TestStart(t *testing.T) {
 			t.Errorf("timed out")
 		}
 	})
+
+	t.Run("Idle span recorded", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			realTime:        true,
+			idleTime:        stubDuration,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:    true,
+			numLoops:      1,
+			cooldownTime:  stubCooldownTime,
+			minUsageTime:  stubMinUsageTime,
+			idleThreshold: time.Minute,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		if len(db.entries) == 0 {
+			t.Fatal("DB not called enough times")
+		}
+		if got := db.entries[0].AppName; got != data.IdleAppName {
+			t.Errorf("got %q, want %q", got, data.IdleAppName)
+		}
+	})
+
+	t.Run("Idle span starts at last input", func(t *testing.T) {
+		times := make([]time.Time, 8)
+		idleTimes := make([]time.Duration, len(times))
+		for i := range times {
+			times[i] = stubTime.Add(time.Duration(i) * time.Second)
+			if i > 1 {
+				idleTimes[i] = time.Duration(i-1) * time.Second
+			}
+		}
+		system := stubOS{
+			applicationName: stubName,
+			times:           times,
+			idleTimes:       idleTimes,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:    true,
+			numLoops:      len(times) - 2,
+			cooldownTime:  stubCooldownTime,
+			minUsageTime:  stubMinUsageTime,
+			idleThreshold: 5 * time.Second,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		totals := make(map[string]time.Duration)
+		for _, entry := range db.entries {
+			totals[entry.AppName] += entry.Duration
+		}
+		want := map[string]time.Duration{stubName: time.Second, data.IdleAppName: 6 * time.Second}
+		if !reflect.DeepEqual(totals, want) {
+			t.Errorf("got %v, want %v", totals, want)
+		}
+		for _, session := range db.sessions {
+			if session.AppName == data.IdleAppName && !session.Start.Equal(times[1]) {
+				t.Errorf("got idle session starting at %v, want %v", session.Start, times[1])
+			}
+		}
+	})
+
+	t.Run("Input below idle threshold", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			idleTime:        time.Second,
+			times:           []time.Time{stubTime, stubTime.Add(2 * time.Second)},
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:    true,
+			numLoops:      1,
+			cooldownTime:  stubCooldownTime,
+			minUsageTime:  stubMinUsageTime,
+			idleThreshold: time.Minute,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		if len(db.entries) == 0 {
+			t.Fatal("DB not called enough times")
+		}
+		if got := db.entries[0].AppName; got != stubName {
+			t.Errorf("got %q, want %q", got, stubName)
+		}
+	})
+
+	t.Run("Idle time error logged", func(t *testing.T) {
+		idleErr := errors.New("xprintidle not found")
+		system := stubOS{
+			applicationName: stubName,
+			realTime:        true,
+			idleErr:         idleErr,
+			shouldLog:       1,
+			logChan:         make(chan string, 10),
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:    true,
+			numLoops:      1,
+			cooldownTime:  stubCooldownTime,
+			minUsageTime:  stubMinUsageTime,
+			idleThreshold: time.Minute,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		select {
+		case msg := <-system.logChan:
+			if want := tracker.ErrIdleTimeText + idleErr.Error(); msg != want {
+				t.Errorf("got %q, want %q", msg, want)
+			}
+		case <-time.After(1 * time.Second):
+			t.Errorf("timed out")
+		}
+		if got := db.entries[0].AppName; got != stubName {
+			t.Errorf("got %q, want %q", got, stubName)
+		}
+	})
+
+	t.Run("Sample error logged once", func(t *testing.T) {
+		sampleErr := errors.New("no active window")
+		system := stubOS{
+			realTime:  true,
+			sampleErr: sampleErr,
+			shouldLog: 1,
+			logChan:   make(chan string, 10),
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     3,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		if got := len(system.logChan); got != 1 {
+			t.Fatalf("got %d messages logged, want 1", got)
+		}
+		if msg, want := <-system.logChan, tracker.ErrSampleText+sampleErr.Error(); msg != want {
+			t.Errorf("got %q, want %q", msg, want)
+		}
+	})
+
+	t.Run("Session written", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			windowTitle:     stubTitle,
+			pid:             42,
+			realTime:        true,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		if len(db.sessions) == 0 {
+			t.Fatal("WriteSession() not called")
+		}
+		session := db.sessions[0]
+		if session.AppName != stubName || session.Title != stubTitle {
+			t.Errorf("got %v, want app %q and title %q", session, stubName, stubTitle)
+		}
+		if session.Class != stubName || session.PID != 42 {
+			t.Errorf("got %v, want class %q and pid %d", session, stubName, 42)
+		}
+		if !session.End.After(session.Start) {
+			t.Errorf("session end %v is not after start %v", session.End, session.Start)
+		}
+		if got, want := session.Duration(), db.entries[0].Duration; got != want {
+			t.Errorf("got %v, want %v", got, want)
+		}
+	})
+
+	t.Run("Status updated", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			realTime:        true,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+		changes := []string{}
+		status := tracker.NewStatus(func(appName string, since time.Time) {
+			changes = append(changes, appName)
+		})
+
+		tracker.Start(context.Background(), &system, &db, &config, status)
+
+		appName, since := status.Current()
+		if appName != stubName {
+			t.Errorf("got %q, want %q", appName, stubName)
+		}
+		if since.IsZero() {
+			t.Error("Status since not set")
+		}
+		if !reflect.DeepEqual(changes, []string{stubName}) {
+			t.Errorf("got %v, want %v", changes, []string{stubName})
+		}
+	})
+
+	t.Run("Cancelled context flushes pending span", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			windowTitle:     stubTitle,
+			realTime:        true,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     -1,
+			cooldownTime: time.Hour,
+			minUsageTime: stubMinUsageTime,
+		}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan struct{})
+
+		go func() {
+			tracker.Start(ctx, &system, &db, &config, nil)
+			close(done)
+		}()
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+
+		select {
+		case <-done:
+		case <-time.After(1 * time.Second):
+			t.Fatal("Start() did not return after cancellation")
+		}
+
+		if len(db.entries) != 1 || db.entries[0].AppName != stubName {
+			t.Fatalf("got %v, want one entry for %q", db.entries, stubName)
+		}
+		if len(db.sessions) != 1 || db.sessions[0].Title != stubTitle {
+			t.Fatalf("got %v, want one session titled %q", db.sessions, stubTitle)
+		}
+		if config.loopNextCalled != 0 {
+			t.Errorf("LoopNext() called %d times after cancellation", config.loopNextCalled)
+		}
+	})
+
+	t.Run("Pause recorded as its own span", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			realTime:        true,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     2,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+		status := tracker.NewStatus(nil)
+		status.Pause(time.Time{})
+
+		tracker.Start(context.Background(), &system, &db, &config, status)
+
+		if len(db.entries) == 0 || len(db.sessions) == 0 {
+			t.Fatal("pause span not written")
+		}
+		for _, entry := range db.entries {
+			if entry.AppName != data.PausedAppName {
+				t.Errorf("got %q, want %q", entry.AppName, data.PausedAppName)
+			}
+		}
+		if appName, _ := status.Current(); appName != data.PausedAppName {
+			t.Errorf("got %q, want %q", appName, data.PausedAppName)
+		}
+	})
+
+	t.Run("Pause expires", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			realTime:        true,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     2,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+		status := tracker.NewStatus(nil)
+		status.Pause(time.Now().Add(stubCooldownTime / 2))
+
+		tracker.Start(context.Background(), &system, &db, &config, status)
+
+		if paused, _ := status.Paused(); paused {
+			t.Error("tracking still paused")
+		}
+		if len(db.entries) < 2 {
+			t.Fatalf("got %v, want pause and application entries", db.entries)
+		}
+		if db.entries[0].AppName != data.PausedAppName || db.entries[1].AppName != stubName {
+			t.Errorf("got %v, want %q followed by %q", db.entries, data.PausedAppName, stubName)
+		}
+	})
+
+	t.Run("Resume", func(t *testing.T) {
+		status := tracker.NewStatus(nil)
+		status.Pause(time.Time{})
+		status.Resume()
+
+		if paused, _ := status.Paused(); paused {
+			t.Error("tracking still paused")
+		}
+	})
+
+	t.Run("Focus change sampled before cooldown", func(t *testing.T) {
+		system := stubWatcher{
+			stubOS:  stubOS{applicationName: stubName, realTime: true},
+			changes: make(chan struct{}, 1),
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: time.Hour,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		system.changes <- struct{}{}
+		done := make(chan struct{})
+		go func() {
+			tracker.Start(context.Background(), &system, &db, &config, nil)
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(1 * time.Second):
+			t.Fatal("focus change not sampled")
+		}
+		if system.sampleCalled != 2 {
+			t.Errorf("got %d samples, want 2", system.sampleCalled)
+		}
+		if len(db.entries) == 0 {
+			t.Error("Write() not called")
+		}
+	})
+
+	t.Run("Closed focus changes fall back to polling", func(t *testing.T) {
+		system := stubWatcher{
+			stubOS:  stubOS{applicationName: stubName, realTime: true},
+			changes: make(chan struct{}),
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     3,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		close(system.changes)
+		start := time.Now()
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		if system.sampleCalled != 4 {
+			t.Errorf("got %d samples, want 4", system.sampleCalled)
+		}
+		if elapsed := time.Since(start); elapsed < 2*stubCooldownTime {
+			t.Errorf("got %v, want at least %v between samples", elapsed, 2*stubCooldownTime)
+		}
+	})
+
+	t.Run("Break not credited", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			shouldLog:       1,
+			logChan:         make(chan string, 10),
+			times: []time.Time{
+				stubTime,
+				stubTime.Add(time.Second),
+				stubTime.Add(2 * time.Second),
+				stubTime.Add(stubDuration),
+				stubTime.Add(stubDuration + time.Second),
+				stubTime.Add(stubDuration + 2*time.Second),
+			},
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     4,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		var total time.Duration
+		for _, entry := range db.entries {
+			total += entry.Duration
+		}
+		if total != 4*time.Second {
+			t.Errorf("got %v, want %v", total, 4*time.Second)
+		}
+		if msg := <-system.logChan; !strings.HasPrefix(msg, tracker.BreakText) {
+			t.Errorf("got %q, want %q prefix", msg, tracker.BreakText)
+		}
+		if got := db.sessions[len(db.sessions)-1].Start; got != stubTime.Add(stubDuration) {
+			t.Errorf("got %v, want session restarted at %v", got, stubTime.Add(stubDuration))
+		}
+	})
+
+	t.Run("Span split at midnight", func(t *testing.T) {
+		midnight := stubTime.AddDate(0, 0, 1)
+		system := stubOS{
+			applicationName: stubName,
+			times:           []time.Time{midnight.Add(-2 * time.Second), midnight.Add(time.Second), midnight.Add(2 * time.Second)},
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		got := make(map[string]time.Duration)
+		for _, entry := range db.entries {
+			got[entry.ID] += entry.Duration
+		}
+		want := map[string]time.Duration{
+			data.CreateID(stubName, stubTime): 2 * time.Second,
+			data.CreateID(stubName, midnight): 2 * time.Second,
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %v, want %v", got, want)
+		}
+		if got := db.sessions[0]; got.Start != midnight.Add(-2*time.Second) || got.End != midnight {
+			t.Errorf("got %v - %v, want session ended at %v", got.Start, got.End, midnight)
+		}
+		if got := db.sessions[len(db.sessions)-1].Start; got != midnight {
+			t.Errorf("got %v, want session restarted at %v", got, midnight)
+		}
+	})
+
+	t.Run("Span split at day start", func(t *testing.T) {
+		dayStart := stubTime.AddDate(0, 0, 1).Add(4 * time.Hour)
+		system := stubOS{
+			applicationName: stubName,
+			times:           []time.Time{dayStart.Add(-time.Second), dayStart.Add(time.Second)},
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+			dayStart:     4 * time.Hour,
+		}
+
+		tracker.Start(context.Background(), &system, &db, &config, nil)
+
+		want := []string{data.CreateID(stubName, stubTime), data.CreateID(stubName, stubTime.AddDate(0, 0, 1))}
+		if len(db.entries) < 2 || db.entries[0].ID != want[0] || db.entries[1].ID != want[1] {
+			t.Errorf("got %v, want entries %v", db.entries, want)
+		}
+	})
+
+	t.Run("Sleep recorded as its own span", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			times:           []time.Time{stubTime, stubTime.Add(stubDuration), stubTime.Add(stubDuration + time.Second)},
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: stubCooldownTime,
+			minUsageTime: stubMinUsageTime,
+		}
+		status := tracker.NewStatus(nil)
+		status.Sleep(true)
+
+		tracker.Start(context.Background(), &system, &db, &config, status)
+
+		if len(db.entries) == 0 || db.entries[0].AppName != data.SleepAppName {
+			t.Fatalf("got %v, want a %q entry", db.entries, data.SleepAppName)
+		}
+		if db.entries[0].Duration != stubDuration {
+			t.Errorf("got %v, want %v", db.entries[0].Duration, stubDuration)
+		}
+	})
+
+	t.Run("Lock sampled right away", func(t *testing.T) {
+		system := stubOS{
+			applicationName: stubName,
+			realTime:        true,
+		}
+		db := stubDB{}
+		config := stubCfg{
+			shouldLoop:   true,
+			numLoops:     1,
+			cooldownTime: time.Hour,
+			minUsageTime: stubMinUsageTime,
+		}
+		status := tracker.NewStatus(nil)
+
+		done := make(chan struct{})
+		go func() {
+			tracker.Start(context.Background(), &system, &db, &config, status)
+			close(done)
+		}()
+		time.Sleep(stubCooldownTime)
+		status.Lock(true)
+
+		select {
+		case <-done:
+		case <-time.After(1 * time.Second):
+			t.Fatal("lock not sampled")
+		}
+		if app, _ := status.Current(); app != data.LockedAppName {
+			t.Errorf("got %q, want %q", app, data.LockedAppName)
+		}
+	})
 }
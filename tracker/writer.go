@@ -0,0 +1,92 @@
+package tracker
+
+import (
+	"github.com/shldhll/hourglass/data"
+
+	"time"
+)
+
+// SpanBufferSize is the number of completed spans which may wait to be
+// written before the tracker waits for the database
+const SpanBufferSize = 16
+
+// span is a completed span of an application and the session it belongs to
+type span struct {
+	entry   data.Entry
+	session data.Session
+}
+
+// writer writes completed spans to the database, in order, from a single
+// goroutine. Errors are handed back to the tracker, which logs them.
+type writer struct {
+	db    data.DB
+	log   func(string)
+	spans chan span
+	errs  chan error
+}
+
+// newWriter starts a writer for the given database
+func newWriter(db data.DB, log func(string)) *writer {
+	w := &writer{
+		db:    db,
+		log:   log,
+		spans: make(chan span, SpanBufferSize),
+		errs:  make(chan error, SpanBufferSize),
+	}
+	go w.run()
+	return w
+}
+
+// run writes spans until the spans channel is closed
+func (w *writer) run() {
+	defer close(w.errs)
+
+	for s := range w.spans {
+		if err := writeSpan(w.db, s.entry, s.session); err != nil {
+			w.errs <- err
+		}
+	}
+}
+
+// write queues the span, waiting while the queue is full. DBCallNoReturn is
+// logged once if the queue is still full after timeout.
+func (w *writer) write(s span, timeout time.Duration) {
+	select {
+	case w.spans <- s:
+		return
+	default:
+	}
+
+	stalled := time.After(timeout)
+	for {
+		select {
+		case w.spans <- s:
+			return
+		case err := <-w.errs:
+			w.log(err.Error())
+		case <-stalled:
+			w.log(DBCallNoReturn)
+			stalled = nil
+		}
+	}
+}
+
+// report logs the errors of the writes finished so far
+func (w *writer) report() {
+	for {
+		select {
+		case err := <-w.errs:
+			w.log(err.Error())
+		default:
+			return
+		}
+	}
+}
+
+// close waits until the queued spans are written and logs their errors
+func (w *writer) close() {
+	close(w.spans)
+	for err := range w.errs {
+		w.log(err.Error())
+	}
+}